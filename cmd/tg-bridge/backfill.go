@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os/signal"
+	"syscall"
+	"tg-bridge/internal/blobstore"
+	"tg-bridge/internal/bridge"
+	"tg-bridge/internal/config"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/persistence"
+	"tg-bridge/internal/temporalpub"
+	"tg-bridge/internal/tgclient"
+	"tg-bridge/internal/tgrecord"
+	"tg-bridge/internal/tgsession"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backfillSupplier string
+	backfillFrom     string
+	backfillTo       string
+	backfillFromID   int64
+	backfillToID     int64
+	backfillRate     float64
+	backfillRestart  bool
+	backfillAccount  int
+)
+
+// backfillCmd defines the `backfill` subcommand
+var backfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Publish history of a configured channel between dates or message ids",
+	Long: `Walks history of the channel of the supplier from the newest message of the range backwards
+and starts the message workflow for each message. Messages already published are skipped, progress
+is checkpointed in Postgres, so an interrupted backfill of the same range continues where it stopped.
+Dates are inclusive days in UTC or RFC 3339 times.
+
+Examples:
+  tg-bridge backfill --supplier water --from 2024-01-01 --to 2024-03-31
+  tg-bridge backfill --supplier gas --from-id 1200 --to-id 1500`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := parseHistoryRange(backfillFrom, backfillTo, backfillFromID, backfillToID)
+		if err != nil {
+			return err
+		}
+		cfg := config.LoadConfig()
+		if backfillAccount < 0 || backfillAccount >= len(cfg.TelegramSessions) {
+			return fmt.Errorf("no Telegram session %d, %d sessions are configured", backfillAccount, len(cfg.TelegramSessions))
+		}
+		supplier := domain.Supplier{Type: backfillSupplier}
+		channelName, ok := cfg.TelegramChannels[supplier]
+		if !ok {
+			return fmt.Errorf("no channel is configured for %s supplier", backfillSupplier)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		return backfill(ctx, cfg, supplier, channelName, r)
+	},
+}
+
+func init() {
+	backfillCmd.Flags().StringVar(&backfillSupplier, "supplier", "", "Supplier type of the channel in TELEGRAM_CHANNELS")
+	backfillCmd.Flags().StringVar(&backfillFrom, "from", "", "Oldest date to publish, e.g. 2024-01-01")
+	backfillCmd.Flags().StringVar(&backfillTo, "to", "", "Newest date to publish, e.g. 2024-03-31")
+	backfillCmd.Flags().Int64Var(&backfillFromID, "from-id", 0, "Oldest message id to publish")
+	backfillCmd.Flags().Int64Var(&backfillToID, "to-id", 0, "Newest message id to publish")
+	backfillCmd.Flags().Float64Var(&backfillRate, "rate", 1, "Max history requests per second")
+	backfillCmd.Flags().BoolVar(&backfillRestart, "restart", false, "Ignore the checkpoint and walk the range again")
+	backfillCmd.Flags().IntVar(&backfillAccount, "account", 0, "Index of the session in TELEGRAM_SESSIONS to walk history with")
+
+	_ = backfillCmd.MarkFlagRequired("supplier")
+	rootCmd.AddCommand(backfillCmd)
+}
+
+func backfill(ctx context.Context, cfg config.Config, supplier domain.Supplier, channelName string, r domain.HistoryRange) error {
+	if err := tgsession.CheckDCMode(cfg.TelegramSessions[backfillAccount], cfg.TelegramTestDC); err != nil {
+		return err
+	}
+	sessionStorage, err := tgsession.CreateInMemorySessionStorage(cfg.TelegramSessions[backfillAccount])
+	if err != nil {
+		return fmt.Errorf("failed to create Telegram session: %w", err)
+	}
+
+	// history is walked slower than the live bridge polls, as both may run with the same account,
+	// pick another account with --account to keep the live one within its limits
+	limits := maps.Clone(cfg.TelegramRateLimits)
+	if limits == nil {
+		limits = make(map[string]float64)
+	}
+	limits[tgclient.MethodClassHistory] = backfillRate
+	rateLimit, err := tgclient.NewRateLimit(limits, nil)
+	if err != nil {
+		return fmt.Errorf("invalid Telegram rate limits: %w", err)
+	}
+	floodWait := tgclient.NewFloodWait(
+		time.Duration(cfg.TelegramFloodWaitMax)*time.Second,
+		cfg.TelegramFloodWaitRetries,
+		nil,
+	)
+	resolver, err := tgclient.NewResolver(cfg.TelegramProxy)
+	if err != nil {
+		return fmt.Errorf("invalid Telegram proxy: %w", err)
+	}
+	middlewares := []telegram.Middleware{floodWait, rateLimit}
+	if cfg.TelegramRecordFile != "" {
+		recorder, err := tgrecord.OpenRecorder(cfg.TelegramRecordFile, tgrecord.HistoryMethods...)
+		if err != nil {
+			return fmt.Errorf("failed to open Telegram record file: %w", err)
+		}
+		defer func() { _ = recorder.Close() }()
+		middlewares = append(middlewares, recorder)
+	}
+	client := tgclient.CreateTelegramClient(cfg.TelegramApiId, cfg.TelegramApiHash, sessionStorage, tgclient.ClientOptions{
+		Middlewares: middlewares,
+		Resolver:    resolver,
+		TestDC:      cfg.TelegramTestDC,
+	})
+
+	db, err := persistence.NewDatabase(cfg.PostgresConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to connect postgres: %w", err)
+	}
+	defer db.Close()
+
+	store, err := blobstore.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to init media store: %w", err)
+	}
+	var media *tgclient.MediaDownloader
+	if store != nil {
+		media = tgclient.NewMediaDownloader(store, cfg.MediaMaxSize)
+	}
+
+	publisher, err := temporalpub.NewPublisher(cfg, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to init temporal publisher: %w", err)
+	}
+	defer func() { _ = publisher.Close() }()
+
+	return client.Run(ctx, func(ctx context.Context) error {
+		err := tgclient.CheckTelegramSession(ctx, client, func() error {
+			return errors.New("not authorized, session is not valid")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to connect to Telegram: %w", err)
+		}
+
+		// peers resolved by the account are cached the way the bridge caches them
+		var cache tgclient.PeerCache = db
+		if len(cfg.TelegramSessions) > 1 {
+			self, err := client.Self(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get self user: %w", err)
+			}
+			cache = tgclient.AccountPeerCache(db, self.ID)
+		}
+		channel, err := tgclient.NewChannel(ctx, client, channelName, supplier, cache)
+		if err != nil {
+			return fmt.Errorf("failed to find channel: %w", err)
+		}
+		if backfillRestart {
+			if err := db.DeleteBackfillCheckpoint(domain.ChatID(channel.Id()), r.Key()); err != nil {
+				return fmt.Errorf("failed to reset backfill checkpoint: %w", err)
+			}
+		}
+
+		// metrics are not served by one-off commands
+		b := bridge.New(cfg, db, publisher, nil, media)
+		return b.Backfill(ctx, supplier, channel, r)
+	})
+}
+
+// parseHistoryRange parses range flags, dates are whole days in UTC unless given as RFC 3339 times.
+func parseHistoryRange(from, to string, fromID, toID int64) (domain.HistoryRange, error) {
+	r := domain.HistoryRange{FromID: domain.MessageID(fromID), ToID: domain.MessageID(toID)}
+	var err error
+	if from != "" {
+		if r.From, err = parseDate(from, false); err != nil {
+			return r, err
+		}
+	}
+	if to != "" {
+		if r.To, err = parseDate(to, true); err != nil {
+			return r, err
+		}
+	}
+	if r.FromID < 0 || r.ToID < 0 || (r.ToID != 0 && r.FromID > r.ToID) {
+		return r, fmt.Errorf("invalid message id range: %d-%d", fromID, toID)
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && r.From.After(r.To) {
+		return r, fmt.Errorf("invalid date range: %s-%s", from, to)
+	}
+	return r, nil
+}
+
+func parseDate(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339 time", s)
+	}
+	if endOfDay {
+		return day.Add(24*time.Hour - time.Second), nil
+	}
+	return day, nil
+}
@@ -5,24 +5,36 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
+	"tg-bridge/internal/blobstore"
+	"tg-bridge/internal/bridge"
 	"tg-bridge/internal/config"
 	"tg-bridge/internal/domain"
 	"tg-bridge/internal/healthserver"
 	"tg-bridge/internal/metricsserver"
 	"tg-bridge/internal/tgclient"
-	"tg-bridge/internal/tgsession"
+	"tg-bridge/internal/tgrecord"
 	"time"
 
 	"tg-bridge/internal/persistence"
 	"tg-bridge/internal/temporalpub"
+
+	"github.com/gotd/td/telegram"
 )
 
 func main() {
+	Execute()
+}
+
+// serve runs the bridge until it is stopped by a signal or the Telegram loop fails.
+func serve() {
 	cfg := config.LoadConfig()
 
 	// Create context for graceful shutdown
@@ -33,19 +45,83 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	sessionStorage, err := tgsession.CreateInMemorySessionStorage(cfg.TelegramSession)
+	// Prometheus metrics, served once the application is started
+	ms := metricsserver.New(fmt.Sprintf(":%d", cfg.MetricsPort))
+
+	// Back off on FLOOD_WAIT and keep API calls within rate limits, so the account is not banned
+	if _, err := tgclient.NewRateLimit(cfg.TelegramRateLimits, nil); err != nil {
+		log.Fatalf("Invalid Telegram rate limits: %v", err)
+	}
+	floodWait := tgclient.NewFloodWait(
+		time.Duration(cfg.TelegramFloodWaitMax)*time.Second,
+		cfg.TelegramFloodWaitRetries,
+		ms.ObserveTelegramFloodWait,
+	)
+
+	// Updates are pushed by Telegram in real time, polling stays as a fallback
+	var updates *tgclient.Updates
+	if cfg.TelegramUpdates {
+		updates = tgclient.NewUpdates(cfg.TelegramPageSize, time.Duration(cfg.TelegramAlbumWait)*time.Second)
+	}
+
+	// Connect through the proxy when egress is only allowed via one
+	resolver, err := tgclient.NewResolver(cfg.TelegramProxy)
+	if err != nil {
+		log.Fatalf("Invalid Telegram proxy: %v", err)
+	}
+
+	// Responses of history calls are captured for replay in tests
+	var recorder *tgrecord.Recorder
+	if cfg.TelegramRecordFile != "" {
+		recorder, err = tgrecord.OpenRecorder(cfg.TelegramRecordFile, tgrecord.HistoryMethods...)
+		if err != nil {
+			log.Fatalf("Failed to open Telegram record file: %v", err)
+		}
+		defer func() { _ = recorder.Close() }()
+		log.Printf("⏺️ Recording Telegram history calls to %s", cfg.TelegramRecordFile)
+	}
+
+	// A client per account, channels move to another account when theirs fails
+	pool, err := tgclient.NewPool(cfg.TelegramApiId, cfg.TelegramApiHash, cfg.TelegramSessions, tgclient.PoolOptions{
+		Updates: updates,
+		Middlewares: func() []telegram.Middleware {
+			// limits are validated above and apply to each account separately
+			rateLimit, _ := tgclient.NewRateLimit(cfg.TelegramRateLimits, ms.ObserveTelegramRateLimitWait)
+			middlewares := []telegram.Middleware{floodWait, rateLimit}
+			if recorder != nil {
+				// innermost, so only responses of Telegram are recorded
+				middlewares = append(middlewares, recorder)
+			}
+			return middlewares
+		},
+		MaxFloodWaits: cfg.TelegramFailoverFloodWaits,
+		Resolver:      resolver,
+		TestDC:        cfg.TelegramTestDC,
+	})
 	if err != nil {
-		log.Fatalf("Failed to create Telegram session: %v", err)
+		log.Fatalf("Failed to create Telegram clients: %v", err)
+	}
+	if cfg.TelegramTestDC {
+		log.Println("🧪 Connecting to Telegram test DCs")
 	}
-	client := tgclient.CreateTelegramClient(cfg.TelegramApiId, cfg.TelegramApiHash, sessionStorage)
 
-	// DB connection for offsets
+	// DB connection for offsets and resolved peers
 	db, err := persistence.NewDatabase(cfg.PostgresConnectionString)
 	if err != nil {
 		log.Fatalf("failed to connect postgres: %v", err)
 	}
 	defer db.Close()
 
+	// Optional store for downloaded media files
+	store, err := blobstore.New(cfg)
+	if err != nil {
+		log.Fatalf("failed to init media store: %v", err)
+	}
+	var media *tgclient.MediaDownloader
+	if store != nil {
+		media = tgclient.NewMediaDownloader(store, cfg.MediaMaxSize)
+	}
+
 	// Publisher for Temporal workflows
 	publisher, err := temporalpub.NewPublisher(
 		cfg, nil, nil,
@@ -70,7 +146,6 @@ func main() {
 	log.Printf("Health/Ready server listening on %s", hs.Addr())
 
 	// Prometheus metrics server
-	ms := metricsserver.New(fmt.Sprintf(":%d", cfg.MetricsPort))
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -89,28 +164,20 @@ func main() {
 		defer wg.Done()
 		defer close(telegramDone) // Signal completion
 
-		err := client.Run(ctx, func(ctx context.Context) error {
-			// Test connection first
-			connectCtx, connectCancel := context.WithTimeout(ctx, 30*time.Second)
-			err := tgclient.CheckTelegramSession(connectCtx, client, func() error {
-				return errors.New("not authorized, session is not valid")
-			})
-			connectCancel()
-			if err != nil {
-				return fmt.Errorf("failed to connect to Telegram: %w", err)
-			}
-
-			// Mark service as ready once Telegram session is validated
+		err := pool.Run(ctx, func(ctx context.Context) error {
+			// Mark service as ready once Telegram sessions are validated
 			hs.SetReady(true)
 
-			if err := tgclient.ReadAuthenticatedUserInfo(ctx, client); err != nil {
-				log.Printf("Couldn't read user info: %v", err)
-			}
+			b := bridge.New(cfg, db, publisher, ms, media)
+			b.UsePool(pool)
 
-			// Resolve configured channels
-			cfg.TelegramChannelsSession = make(map[domain.Supplier]tgclient.Channel, len(cfg.TelegramChannels))
-			for supplier, channelName := range cfg.TelegramChannels {
-				channel, err := tgclient.NewChannel(ctx, client, channelName, supplier)
+			// Resolve configured channels, in a stable order so they are spread across accounts the same way
+			suppliers := slices.SortedFunc(maps.Keys(cfg.TelegramChannels), func(a, b domain.Supplier) int {
+				return strings.Compare(a.Type, b.Type)
+			})
+			for _, supplier := range suppliers {
+				channelName := cfg.TelegramChannels[supplier]
+				channel, err := pool.Open(ctx, supplier, channelName, db)
 				if err != nil {
 					return fmt.Errorf("failed to find channel: %w", err)
 				}
@@ -118,66 +185,26 @@ func main() {
 					supplier.Type,
 					channelName,
 					channel.Id())
-				cfg.TelegramChannelsSession[supplier] = *channel
-			}
-
-			// Main polling loop: for each channel, fetch messages from offset,
-			// start workflow per message, then persist offsets.
-			interval := time.Duration(cfg.TelegramFetchInterval) * time.Second
-
-			for {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				default:
-				}
-
-				var toPersist []domain.Message
-
-				for supplier, ch := range cfg.TelegramChannelsSession {
-					// Load the last processed message id (offset) per chat
-					offset, err := db.GetLastMessageID(domain.ChatID(ch.Id()))
-					if err != nil {
-						log.Printf("get offset error for supplier %s: %v", supplier.Type, err)
-						continue
-					}
-
-					// Fetch messages after offset
-					msgs, err := ch.Messages(ctx, cfg.TelegramPageSize, int(offset))
-					if err != nil {
-						log.Printf("fetch messages error for supplier %s: %v", supplier.Type, err)
-						continue
-					}
-					if len(msgs) == 0 {
-						continue
-					}
-
-					// Business metric: count received messages per Telegram channel (username)
-					channelName := cfg.TelegramChannels[supplier]
-					ms.AddTelegramChannelMessages(channelName, len(msgs))
-
-					// Start workflow per message
-					for _, m := range msgs {
-						if _, _, err := publisher.StartTelegramWorkflow(ctx, m); err != nil {
-							log.Printf("start workflow error (supplier=%s, msg=%d): %v", supplier.Type, m.ID, err)
-							// continue with other messages; offset will advance on successful saves
-						}
-					}
-
-					// Collect for batch persistence of max offsets per chat
-					toPersist = append(toPersist, msgs...)
+				if oldUsername, renamed := channel.RenamedFrom(); renamed {
+					// offsets are kept by id, so the channel continues from where it stopped
+					log.Printf("✏️ Channel %d of %s supplier was renamed from @%s to @%s",
+						channel.Id(), supplier.Type, oldUsername, channel.Username())
+					ms.AddTelegramChannelRename(channelName)
 				}
-
-				// Persist offsets (per chat only max is sent inside)
-				if len(toPersist) > 0 {
-					if err := db.SaveLastMessageID(toPersist); err != nil {
-						log.Printf("save offsets error: %v", err)
+				if slices.Contains(cfg.TelegramComments, supplier.Type) {
+					if err := channel.FollowComments(ctx); err != nil {
+						return fmt.Errorf("failed to follow comments of %s: %w", channelName, err)
 					}
+					discussionID, _ := channel.DiscussionID()
+					log.Printf("💬 Following comments of %s in discussion group %d", channelName, discussionID)
 				}
-
-				// Sleep before the next iteration to avoid rate limits
-				time.Sleep(interval)
+				b.AddChannel(supplier, channelName, channel)
 			}
+
+			// Main loop: publish pushed messages and poll channels from offsets
+			// to catch up with the missed ones.
+			interval := time.Duration(cfg.TelegramFetchInterval) * time.Second
+			return b.Run(ctx, interval, updates)
 		})
 		if err != nil {
 			hs.SetReady(false)
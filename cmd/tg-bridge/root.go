@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// rootCmd defines the `tg-bridge` command, which runs the bridge itself
+var rootCmd = &cobra.Command{
+	Use:   "tg-bridge",
+	Short: "Bridge messages of Telegram channels to Temporal workflows",
+	Long: `tg-bridge polls configured Telegram channels and starts a Temporal workflow per message.
+It is configured by environment variables, see README.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		serve()
+	},
+}
+
+// Execute is called by main.main()
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"tg-bridge/internal/config"
+)
+
+// BlobStore keeps downloaded media files. Keys are derived from file content,
+// so storing the same key twice always means the same content.
+type BlobStore interface {
+	// Exists returns URI of the blob stored under key, if there is one.
+	Exists(ctx context.Context, key string) (uri string, found bool, err error)
+	// Put stores size bytes read from r under key and returns URI of the blob.
+	Put(ctx context.Context, key string, contentType string, r io.Reader, size int64) (uri string, err error)
+}
+
+// New creates blob store selected by MEDIA_STORE, nil store means media downloading is disabled.
+func New(cfg config.Config) (BlobStore, error) {
+	switch cfg.MediaStore {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFileStore(cfg.MediaDir)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media store: %q", cfg.MediaStore)
+	}
+}
@@ -0,0 +1,110 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	testBlobStore(t, store, func(uri string) string {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		require.Equal(t, "file", u.Scheme)
+		data, err := os.ReadFile(u.Path)
+		require.NoError(t, err)
+		return string(data)
+	})
+}
+
+// fakeS3 is an in-memory stand-in for S3 object API, enough for HEAD and PUT of objects.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodHead:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.objects[r.URL.Path] = data
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	backend := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewTLSServer(backend)
+	defer server.Close()
+
+	store, err := NewS3Store(S3Options{
+		Endpoint:  strings.TrimPrefix(server.URL, "https://"),
+		Bucket:    "media",
+		Region:    "us-east-1",
+		AccessKey: "key",
+		SecretKey: "secret",
+		UseSSL:    true,
+		Transport: server.Client().Transport,
+	})
+	require.NoError(t, err)
+
+	testBlobStore(t, store, func(uri string) string {
+		require.True(t, strings.HasPrefix(uri, "s3://media/"), uri)
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		return string(backend.objects["/media/"+strings.TrimPrefix(uri, "s3://media/")])
+	})
+}
+
+func testBlobStore(t *testing.T, store BlobStore, read func(uri string) string) {
+	t.Helper()
+	ctx := context.Background()
+	const key = "sha256/ab/abcdef.pdf"
+
+	_, found, err := store.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	content := "outage schedule"
+	uri, err := store.Put(ctx, key, "application/pdf", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	assert.Equal(t, content, read(uri))
+
+	existing, found, err := store.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, uri, existing)
+}
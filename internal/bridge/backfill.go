@@ -0,0 +1,74 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgclient"
+	"time"
+)
+
+// Backfill publishes messages of the history range of the channel, walking it from the newest message
+// backwards. Progress is checkpointed after each page, so an interrupted backfill continues where it
+// stopped. Messages already published by the bridge or a previous run are skipped by their workflow id.
+// Offsets of the bridge are not changed.
+func (b *Bridge) Backfill(ctx context.Context, supplier domain.Supplier, ch *tgclient.Channel, r domain.HistoryRange) error {
+	chatID := domain.ChatID(ch.Id())
+	key := r.Key()
+
+	cp, ok, err := b.db.GetBackfillCheckpoint(chatID, key)
+	if err != nil {
+		return fmt.Errorf("get backfill checkpoint: %w", err)
+	}
+	switch {
+	case ok && cp.Done:
+		log.Printf("backfill of %s for supplier %s is already done", key, supplier.Type)
+		return nil
+	case ok:
+		log.Printf("resuming backfill of %s for supplier %s before message %d", key, supplier.Type, cp.Cursor)
+	}
+
+	var published, skipped int
+	cursor := cp.Cursor
+	for {
+		msgs, next, err := ch.BackfillPage(ctx, r, cursor, b.cfg.TelegramPageSize)
+		if err != nil {
+			return fmt.Errorf("fetch history before message %d: %w", cursor, err)
+		}
+
+		b.resolveReplies(ctx, supplier, ch, msgs)
+		for i := range msgs {
+			b.prepare(ctx, ch, &msgs[i])
+			_, started, err := b.publisher.StartTelegramBackfillWorkflow(ctx, msgs[i])
+			if err != nil {
+				// the page is published again on resume
+				return fmt.Errorf("start workflow (msg=%d): %w", msgs[i].ID, err)
+			}
+			if started {
+				published++
+			} else {
+				skipped++
+			}
+		}
+
+		cp = domain.BackfillCheckpoint{
+			ChatID:    chatID,
+			Range:     key,
+			Cursor:    next,
+			Done:      next == 0,
+			UpdatedAt: time.Now().UTC(),
+		}
+		if err := b.db.SaveBackfillCheckpoint(cp); err != nil {
+			return fmt.Errorf("save backfill checkpoint: %w", err)
+		}
+		if next == 0 {
+			break
+		}
+		log.Printf("backfill of supplier %s reached message %d: %d published, %d skipped", supplier.Type, next, published, skipped)
+		cursor = next
+	}
+
+	log.Printf("✅ backfill of %s for supplier %s is done: %d published, %d skipped", key, supplier.Type, published, skipped)
+	return nil
+}
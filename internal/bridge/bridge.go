@@ -0,0 +1,639 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"slices"
+	"tg-bridge/internal/config"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/metricsserver"
+	"tg-bridge/internal/persistence"
+	"tg-bridge/internal/temporalpub"
+	"tg-bridge/internal/tgclient"
+	"time"
+)
+
+// Bridge moves messages of configured Telegram channels to Temporal workflows
+// and keeps the last processed message id per chat in Postgres.
+type Bridge struct {
+	cfg       config.Config
+	db        *persistence.DatabaseConnection
+	publisher *temporalpub.Publisher
+	metrics   *metricsserver.Server
+	media     *tgclient.MediaDownloader
+
+	channels map[domain.Supplier]*tgclient.Channel
+	names    map[domain.Supplier]string
+	// pool moves channels between accounts, nil for a single client
+	pool *tgclient.Pool
+}
+
+func New(
+	cfg config.Config,
+	db *persistence.DatabaseConnection,
+	publisher *temporalpub.Publisher,
+	metrics *metricsserver.Server,
+	media *tgclient.MediaDownloader,
+) *Bridge {
+	return &Bridge{
+		cfg:       cfg,
+		db:        db,
+		publisher: publisher,
+		metrics:   metrics,
+		media:     media,
+		channels:  make(map[domain.Supplier]*tgclient.Channel),
+		names:     make(map[domain.Supplier]string),
+	}
+}
+
+// AddChannel registers resolved channel of the supplier, name is used as metrics label.
+func (b *Bridge) AddChannel(supplier domain.Supplier, name string, channel *tgclient.Channel) {
+	b.channels[supplier] = channel
+	b.names[supplier] = name
+}
+
+// UsePool enables failover of channels opened by the pool to its other accounts.
+func (b *Bridge) UsePool(pool *tgclient.Pool) {
+	b.pool = pool
+}
+
+// Run polls channels every interval until ctx is done. Messages received from updates
+// are published as soon as they arrive, polling is then only a fallback that catches up
+// messages missed while disconnected. updates may be nil when push mode is disabled.
+func (b *Bridge) Run(ctx context.Context, interval time.Duration, updates *tgclient.Updates) error {
+	var (
+		messages  <-chan domain.Message
+		edits     <-chan domain.Message
+		deletions <-chan domain.MessageDeletion
+		events    <-chan domain.ServiceEvent
+		pins      <-chan domain.ChatID
+		catchUp   <-chan int64
+	)
+	if updates != nil {
+		messages = updates.Messages()
+		edits = updates.Edits()
+		deletions = updates.Deletions()
+		events = updates.Events()
+		pins = updates.Pins()
+		catchUp = updates.CatchUp()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Engagement snapshots are optional and taken on their own schedule
+	var engagement <-chan time.Time
+	if b.cfg.TelegramEngagementWindow > 0 {
+		engagementTicker := time.NewTicker(time.Duration(b.cfg.TelegramEngagementInterval) * time.Second)
+		defer engagementTicker.Stop()
+		engagement = engagementTicker.C
+	}
+
+	// Catch up with everything posted while the bridge was down before handling updates,
+	// otherwise a pushed message would move the offset past the missed ones.
+	b.Poll(ctx)
+	if updates != nil && b.trackPinned() {
+		for supplier, ch := range b.channels {
+			b.CheckPinned(ctx, supplier, ch)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			b.Poll(ctx)
+		case <-engagement:
+			b.SnapshotEngagement(ctx)
+		case channelID := <-catchUp:
+			log.Printf("updates gap for channel %d, catching up from history", channelID)
+			b.Poll(ctx)
+		case m := <-messages:
+			b.publishPushed(ctx, m)
+		case m := <-edits:
+			supplier, ok := b.supplierOf(m.ChatID)
+			if !ok {
+				continue
+			}
+			b.PublishEdits(ctx, supplier, []domain.Message{m})
+		case d := <-deletions:
+			supplier, ok := b.supplierOf(d.ChatID)
+			if !ok {
+				continue
+			}
+			b.PublishDeletions(ctx, supplier, d.ChatID, []domain.MessageID{d.ID})
+		case e := <-events:
+			supplier, ok := b.supplierOf(e.ChatID)
+			if !ok {
+				continue
+			}
+			b.PublishEvents(ctx, supplier, []domain.ServiceEvent{e})
+		case chatID := <-pins:
+			supplier, ok := b.supplierOf(chatID)
+			if !ok || !b.trackPinned() {
+				continue
+			}
+			b.CheckPinned(ctx, supplier, b.channels[supplier])
+		}
+	}
+}
+
+// publishPushed publishes a message received from updates unless polling got it already:
+// updates keep arriving while a poll runs, so the message may be in the fetched history too.
+func (b *Bridge) publishPushed(ctx context.Context, m domain.Message) {
+	supplier, ok := b.supplierOf(m.ChatID)
+	if !ok {
+		return
+	}
+	offset, err := b.db.GetLastMessageID(m.ChatID)
+	if err != nil {
+		log.Printf("get offset error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	if m.LastID() <= offset {
+		return
+	}
+	b.Publish(ctx, supplier, []domain.Message{m})
+}
+
+// Poll fetches messages after the stored offset for each channel, starts workflow per message,
+// then persists offsets. Recent messages are re-scanned for edits and deletions when they are tracked.
+func (b *Bridge) Poll(ctx context.Context) {
+	for supplier, ch := range b.channels {
+		if b.pool != nil && !b.pool.Healthy(supplier) {
+			if ch = b.failover(ctx, supplier); ch == nil {
+				continue
+			}
+		}
+
+		// Load the last processed message id (offset) per chat
+		offset, err := b.db.GetLastMessageID(domain.ChatID(ch.Id()))
+		if err != nil {
+			log.Printf("get offset error for supplier %s: %v", supplier.Type, err)
+			continue
+		}
+
+		// Fetch messages after offset
+		msgs, events, err := ch.Messages(ctx, b.cfg.TelegramPageSize, int(offset), b.cfg.TelegramMaxPages)
+		if b.pool != nil && b.pool.Observe(supplier, err) {
+			b.failover(ctx, supplier)
+		}
+		if err != nil {
+			log.Printf("fetch messages error for supplier %s: %v", supplier.Type, err)
+			continue
+		}
+
+		ready := b.holdBackAlbum(msgs)
+		if len(ready) < len(msgs) {
+			// events after the held back album would move the offset past it
+			held := msgs[len(msgs)-1].ID
+			events = slices.DeleteFunc(events, func(e domain.ServiceEvent) bool { return e.ID > held })
+		}
+
+		b.Publish(ctx, supplier, ready)
+		b.PublishEvents(ctx, supplier, events)
+
+		if b.trackEdits() && b.cfg.TelegramEditWindow > 0 {
+			b.rescanRecent(ctx, supplier, ch)
+		}
+
+		if b.trackDeletions() && b.cfg.TelegramDeleteWindow > 0 {
+			b.verifyRecent(ctx, supplier, ch)
+		}
+
+		// with updates pins are checked when Telegram reports them
+		if b.trackPinned() && !b.cfg.TelegramUpdates {
+			b.CheckPinned(ctx, supplier, ch)
+		}
+
+		if _, ok := ch.DiscussionID(); ok {
+			b.PollComments(ctx, supplier, ch)
+		}
+	}
+}
+
+// PollComments publishes new comments under the latest posts of the channel. Comments of each post
+// have their own offset, posts without comments newer than it are skipped without fetching.
+func (b *Bridge) PollComments(ctx context.Context, supplier domain.Supplier, ch *tgclient.Channel) {
+	posts, err := ch.CommentedPosts(ctx, b.cfg.TelegramCommentsWindow)
+	if err != nil {
+		log.Printf("fetch commented posts error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+
+	chatID := domain.ChatID(ch.Id())
+	ids := make([]domain.MessageID, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	offsets, err := b.db.GetCommentOffsets(chatID, ids)
+	if err != nil {
+		log.Printf("get comment offsets error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+
+	for _, post := range posts {
+		offset := offsets[post.ID]
+		if post.LastCommentID <= offset {
+			continue
+		}
+		comments, err := ch.Comments(ctx, post.ID, b.cfg.TelegramPageSize, int(offset), b.cfg.TelegramMaxPages)
+		if err != nil {
+			log.Printf("fetch comments error for supplier %s (post=%d): %v", supplier.Type, post.ID, err)
+			continue
+		}
+		b.PublishComments(ctx, supplier, post.ID, b.holdBackAlbum(comments))
+	}
+}
+
+// PublishComments starts workflow per comment of the post and persists the offset of its comments.
+func (b *Bridge) PublishComments(ctx context.Context, supplier domain.Supplier, post domain.MessageID, comments []domain.Message) {
+	if len(comments) == 0 {
+		return
+	}
+
+	b.metrics.AddTelegramChannelComments(b.names[supplier], len(comments))
+
+	b.resolveReplies(ctx, supplier, b.channels[supplier], comments)
+	for i := range comments {
+		b.prepare(ctx, b.channels[supplier], &comments[i])
+	}
+
+	for _, m := range comments {
+		if _, _, err := b.publisher.StartTelegramWorkflow(ctx, m); err != nil {
+			log.Printf("start workflow error (supplier=%s, post=%d, comment=%d): %v", supplier.Type, post, m.ID, err)
+		}
+	}
+
+	last := comments[len(comments)-1]
+	if err := b.db.SaveCommentOffset(last.CommentOf.ChatID, post, last.LastID()); err != nil {
+		log.Printf("save comment offset error: %v", err)
+	}
+}
+
+// failover moves the channel of the supplier to another account of the pool, it is polled from
+// the same offset. Returns nil if no other account could open it.
+func (b *Bridge) failover(ctx context.Context, supplier domain.Supplier) *tgclient.Channel {
+	ch, err := b.pool.Open(ctx, supplier, b.names[supplier], b.db)
+	if err != nil {
+		log.Printf("failover error for supplier %s: %v", supplier.Type, err)
+		return nil
+	}
+	if slices.Contains(b.cfg.TelegramComments, supplier.Type) {
+		if err := ch.FollowComments(ctx); err != nil {
+			log.Printf("follow comments error for supplier %s: %v", supplier.Type, err)
+		}
+	}
+	b.channels[supplier] = ch
+	b.metrics.AddTelegramChannelFailover(b.names[supplier])
+	return ch
+}
+
+// holdBackAlbum drops the latest album if it was posted just now, as the rest of its parts may
+// not be in history yet. Offset stays before the album, so it is fetched again on the next poll.
+// Albums crossing the fetched range are completed or left for the next poll by the channel.
+func (b *Bridge) holdBackAlbum(msgs []domain.Message) []domain.Message {
+	if len(msgs) == 0 {
+		return msgs
+	}
+	last := msgs[len(msgs)-1]
+	wait := time.Duration(b.cfg.TelegramAlbumWait) * time.Second
+	if last.GroupedID != 0 && time.Since(last.Date) < wait {
+		return msgs[:len(msgs)-1]
+	}
+	return msgs
+}
+
+// rescanRecent re-fetches the latest messages of the channel to find edits.
+func (b *Bridge) rescanRecent(ctx context.Context, supplier domain.Supplier, ch *tgclient.Channel) {
+	recent, err := ch.Recent(ctx, b.cfg.TelegramEditWindow)
+	if err != nil {
+		log.Printf("fetch recent messages error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	b.PublishEdits(ctx, supplier, recent)
+}
+
+// verifyRecent checks that the latest published messages of the channel still exist.
+func (b *Bridge) verifyRecent(ctx context.Context, supplier domain.Supplier, ch *tgclient.Channel) {
+	chatID := domain.ChatID(ch.Id())
+	ids, err := b.db.GetRecentMessageIDs(chatID, b.cfg.TelegramDeleteWindow)
+	if err != nil {
+		log.Printf("get recent message ids error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	missing, err := ch.Missing(ctx, ids)
+	if err != nil {
+		log.Printf("verify messages error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	b.PublishDeletions(ctx, supplier, chatID, missing)
+}
+
+// Publish starts workflow per message and persists max offset per chat.
+func (b *Bridge) Publish(ctx context.Context, supplier domain.Supplier, msgs []domain.Message) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	// Business metric: count received messages per Telegram channel (username)
+	b.metrics.AddTelegramChannelMessages(b.names[supplier], len(msgs))
+
+	b.resolveReplies(ctx, supplier, b.channels[supplier], msgs)
+	for i := range msgs {
+		b.prepare(ctx, b.channels[supplier], &msgs[i])
+	}
+
+	for _, m := range msgs {
+		if _, _, err := b.publisher.StartTelegramWorkflow(ctx, m); err != nil {
+			log.Printf("start workflow error (supplier=%s, msg=%d): %v", supplier.Type, m.ID, err)
+			// continue with other messages; offset will advance on successful saves
+		}
+	}
+
+	// Remember published messages, so the following edits carry the old text
+	// and deletions are reported only for messages consumers know about
+	if b.trackEdits() || b.trackDeletions() {
+		if err := b.db.SaveMessageVersions(msgs); err != nil {
+			log.Printf("save message versions error: %v", err)
+		}
+	}
+
+	// Persist offsets (per chat only max is sent inside)
+	if err := b.db.SaveLastMessageID(msgs); err != nil {
+		log.Printf("save offsets error: %v", err)
+	}
+}
+
+// PublishEdits starts edit workflow for messages edited after their last known version.
+// Messages never seen before are only recorded, as there is no old text to compare with.
+func (b *Bridge) PublishEdits(ctx context.Context, supplier domain.Supplier, msgs []domain.Message) {
+	if !b.trackEdits() {
+		return
+	}
+
+	edited := make([]domain.Message, 0, len(msgs))
+	ids := make([]domain.MessageID, 0, len(msgs))
+	for _, m := range msgs {
+		if m.EditDate != nil {
+			edited = append(edited, m)
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(edited) == 0 {
+		return
+	}
+
+	known, err := b.db.GetMessageVersions(edited[0].ChatID, ids)
+	if err != nil {
+		log.Printf("get message versions error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+
+	// rescans return the same edited messages every poll, only newer versions are handled
+	toSave := make([]domain.Message, 0, len(edited))
+	changed := make([]domain.Message, 0, len(edited))
+	oldTexts := make(map[domain.MessageID]string, len(edited))
+	for _, m := range edited {
+		version, ok := known[m.ID]
+		if ok && version.AlbumID != 0 && len(m.AlbumIDs) < 2 {
+			// pushed edits carry a single part, while the album was published as a whole
+			album, found := b.album(ctx, supplier, m.ChatID, version.AlbumID)
+			if !found || album.EditDate == nil {
+				continue
+			}
+			m = album
+		}
+		if _, seen := oldTexts[m.ID]; seen {
+			continue
+		}
+		switch {
+		case !ok:
+			toSave = append(toSave, m)
+		case m.EditDate.After(version.EditDate):
+			changed = append(changed, m)
+			oldTexts[m.ID] = version.Text
+		}
+	}
+
+	b.resolveReplies(ctx, supplier, b.channels[supplier], changed)
+	for _, m := range changed {
+		b.prepare(ctx, b.channels[supplier], &m)
+		edit := domain.MessageEdit{Message: m, OldText: oldTexts[m.ID]}
+		if _, _, err := b.publisher.StartTelegramEditWorkflow(ctx, edit); err != nil {
+			log.Printf("start edit workflow error (supplier=%s, msg=%d): %v", supplier.Type, m.ID, err)
+			// keep the old version, edit is retried on the next scan
+			continue
+		}
+		log.Printf("✏️ Message %d of %s supplier was edited", m.ID, supplier.Type)
+		toSave = append(toSave, m)
+	}
+
+	if err := b.db.SaveMessageVersions(toSave); err != nil {
+		log.Printf("save message versions error: %v", err)
+	}
+}
+
+// album re-fetches the parts of the album published as albumID, false if it can't be fetched.
+func (b *Bridge) album(ctx context.Context, supplier domain.Supplier, chatID domain.ChatID, albumID domain.MessageID) (domain.Message, bool) {
+	ch := b.channels[supplier]
+	if ch == nil {
+		return domain.Message{}, false
+	}
+	ids, err := b.db.GetAlbumPartIDs(chatID, albumID)
+	if err != nil {
+		log.Printf("get album parts error for supplier %s (album=%d): %v", supplier.Type, albumID, err)
+		return domain.Message{}, false
+	}
+	album, ok, err := ch.Album(ctx, ids)
+	if err != nil {
+		log.Printf("fetch album error for supplier %s (album=%d): %v", supplier.Type, albumID, err)
+		return domain.Message{}, false
+	}
+	return album, ok
+}
+
+// PublishDeletions starts delete workflow for published messages of the chat which were deleted.
+// Deletions of messages never published or already reported are ignored.
+func (b *Bridge) PublishDeletions(ctx context.Context, supplier domain.Supplier, chatID domain.ChatID, ids []domain.MessageID) {
+	if !b.trackDeletions() || len(ids) == 0 {
+		return
+	}
+
+	live, err := b.db.GetLiveMessageIDs(chatID, ids)
+	if err != nil {
+		log.Printf("get live message ids error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	toSave := make([]domain.MessageDeletion, 0, len(live))
+	for _, id := range live {
+		deletion := domain.MessageDeletion{ID: id, ChatID: chatID, DetectedAt: now}
+		if _, _, err := b.publisher.StartTelegramDeleteWorkflow(ctx, deletion); err != nil {
+			log.Printf("start delete workflow error (supplier=%s, msg=%d): %v", supplier.Type, id, err)
+			// not recorded, deletion is retried on the next verification
+			continue
+		}
+		log.Printf("🗑️ Message %d of %s supplier was deleted", id, supplier.Type)
+		toSave = append(toSave, deletion)
+	}
+
+	if err := b.db.SaveMessageDeletions(toSave); err != nil {
+		log.Printf("save message deletions error: %v", err)
+	}
+}
+
+// PublishEvents logs service events and starts event workflow per event when it is configured.
+func (b *Bridge) PublishEvents(ctx context.Context, supplier domain.Supplier, events []domain.ServiceEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	for _, e := range events {
+		log.Printf("🔔 %s event %d in chat %d of %s supplier", e.Kind, e.ID, e.ChatID, supplier.Type)
+		if b.cfg.TemporalEventWorkflowType == "" {
+			continue
+		}
+		if _, _, err := b.publisher.StartTelegramEventWorkflow(ctx, e); err != nil {
+			log.Printf("start event workflow error (supplier=%s, event=%d): %v", supplier.Type, e.ID, err)
+		}
+	}
+
+	// Events share ids with messages, offset has to move past them as well
+	if err := b.db.SaveLastEventID(events); err != nil {
+		log.Printf("save offsets error: %v", err)
+	}
+}
+
+// CheckPinned compares the message pinned in the channel with the recorded one and starts
+// pinned workflow when it changed. The change is retried on the next check if workflow fails to start.
+// The first check of a channel only records its pinned message.
+func (b *Bridge) CheckPinned(ctx context.Context, supplier domain.Supplier, ch *tgclient.Channel) {
+	chatID := domain.ChatID(ch.Id())
+	pinned, err := ch.PinnedID(ctx)
+	if err != nil {
+		log.Printf("get pinned message error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	previous, recorded, err := b.db.GetPinnedMessageID(chatID)
+	if err != nil {
+		log.Printf("get recorded pinned message error for supplier %s: %v", supplier.Type, err)
+		return
+	}
+	if !recorded {
+		change := domain.PinnedChange{ChatID: chatID, MessageID: pinned, DetectedAt: time.Now().UTC()}
+		if err := b.db.SavePinnedMessageID(change); err != nil {
+			log.Printf("save pinned message error: %v", err)
+		}
+		return
+	}
+	if pinned == previous {
+		return
+	}
+
+	change := domain.PinnedChange{
+		ChatID:     chatID,
+		MessageID:  pinned,
+		PreviousID: previous,
+		DetectedAt: time.Now().UTC(),
+	}
+	if _, _, err := b.publisher.StartTelegramPinnedWorkflow(ctx, change); err != nil {
+		log.Printf("start pinned workflow error (supplier=%s, msg=%d): %v", supplier.Type, pinned, err)
+		return
+	}
+	log.Printf("📌 Pinned message of %s supplier changed from %d to %d", supplier.Type, previous, pinned)
+
+	if err := b.db.SavePinnedMessageID(change); err != nil {
+		log.Printf("save pinned message error: %v", err)
+	}
+}
+
+// SnapshotEngagement re-fetches the latest messages of each channel, records their views, forwards
+// and reactions and sets engagement gauges to their totals.
+func (b *Bridge) SnapshotEngagement(ctx context.Context) {
+	now := time.Now().UTC()
+	for supplier, ch := range b.channels {
+		if b.pool != nil && !b.pool.Healthy(supplier) {
+			if ch = b.failover(ctx, supplier); ch == nil {
+				continue
+			}
+		}
+
+		recent, err := ch.Recent(ctx, b.cfg.TelegramEngagementWindow)
+		if b.pool != nil && b.pool.Observe(supplier, err) {
+			b.failover(ctx, supplier)
+		}
+		if err != nil {
+			log.Printf("fetch recent messages error for supplier %s: %v", supplier.Type, err)
+			continue
+		}
+
+		var views, forwards, reactions int
+		snapshots := make([]domain.Engagement, 0, len(recent))
+		for _, m := range recent {
+			snapshot := domain.EngagementOf(m, now)
+			views += snapshot.Views
+			forwards += snapshot.Forwards
+			reactions += snapshot.Reactions
+			snapshots = append(snapshots, snapshot)
+		}
+		b.metrics.SetTelegramChannelEngagement(b.names[supplier], views, forwards, reactions)
+
+		if err := b.db.SaveEngagement(snapshots); err != nil {
+			log.Printf("save engagement error for supplier %s: %v", supplier.Type, err)
+		}
+	}
+}
+
+// resolveReplies embeds text of replied messages of the channel when enabled. Messages are published
+// without it if the replied messages can't be fetched.
+func (b *Bridge) resolveReplies(ctx context.Context, supplier domain.Supplier, ch *tgclient.Channel, msgs []domain.Message) {
+	if !b.cfg.TelegramResolveReplies || ch == nil {
+		return
+	}
+	if err := ch.ResolveReplies(ctx, msgs); err != nil {
+		log.Printf("resolve replies error for supplier %s: %v", supplier.Type, err)
+	}
+}
+
+// prepare completes message of the channel before publishing: downloads its files when media store
+// is configured and renders text in configured formats.
+func (b *Bridge) prepare(ctx context.Context, ch *tgclient.Channel, msg *domain.Message) {
+	if b.media != nil {
+		b.media.Attach(ctx, ch.API(), msg)
+	}
+	for _, format := range b.cfg.TelegramTextFormats {
+		switch format {
+		case "markdown":
+			msg.Markdown = domain.RenderMarkdown(msg.Text, msg.Entities)
+		case "html":
+			msg.HTML = domain.RenderHTML(msg.Text, msg.Entities)
+		}
+	}
+}
+
+func (b *Bridge) trackEdits() bool {
+	return b.cfg.TemporalEditWorkflowType != ""
+}
+
+func (b *Bridge) trackDeletions() bool {
+	return b.cfg.TemporalDeleteWorkflowType != ""
+}
+
+func (b *Bridge) trackPinned() bool {
+	return b.cfg.TemporalPinnedWorkflowType != ""
+}
+
+func (b *Bridge) supplierOf(chatID domain.ChatID) (domain.Supplier, bool) {
+	for supplier, ch := range b.channels {
+		if domain.ChatID(ch.Id()) == chatID {
+			return supplier, true
+		}
+	}
+	return domain.Supplier{}, false
+}
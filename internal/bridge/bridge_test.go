@@ -0,0 +1,241 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"tg-bridge/internal/config"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/metricsserver"
+	"tg-bridge/internal/persistence"
+	"tg-bridge/internal/temporalpub"
+	"tg-bridge/internal/tgclient"
+	"tg-bridge/internal/tgfake"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/mock"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+)
+
+func startPostgres(t *testing.T) *persistence.DatabaseConnection {
+	t.Helper()
+
+	ctx := context.Background()
+	container, err := tcpostgres.Run(ctx,
+		"postgres:17",
+		tcpostgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	t.Cleanup(func() { _ = container.Terminate(context.Background()) })
+
+	connStr, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get connection string: %v", err)
+	}
+	db, err := persistence.NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func posts(ids ...int) []tg.MessageClass {
+	result := make([]tg.MessageClass, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, &tg.Message{ID: id, Message: "outage", Date: 1700000000 + id})
+	}
+	return result
+}
+
+// startedWorkflows are message workflows started by a test bridge.
+type startedWorkflows struct {
+	ids  []string
+	msgs []domain.Message
+}
+
+// newTestBridge returns a bridge polling the channel served by api and the workflows it started.
+// configure adjusts the config before the bridge and its publisher are created.
+func newTestBridge(t *testing.T, api *tgfake.Invoker, channel *tg.Channel, configure ...func(*config.Config)) (*Bridge, *startedWorkflows) {
+	t.Helper()
+	ctx := context.Background()
+	db := startPostgres(t)
+	water := domain.Supplier{Type: "water"}
+
+	started := &startedWorkflows{}
+	tc := mocks.NewClient(t)
+	run := &mocks.WorkflowRun{}
+	run.On("GetID").Return("").Maybe()
+	run.On("GetRunID").Return("").Maybe()
+	tc.On("ExecuteWorkflow", mock.Anything, mock.Anything, "TelegramMessage", mock.Anything).
+		Run(func(args mock.Arguments) {
+			started.ids = append(started.ids, args.Get(1).(client.StartWorkflowOptions).ID)
+			started.msgs = append(started.msgs, args.Get(3).(domain.Message))
+		}).
+		Return(run, nil)
+	for _, workflowType := range []string{"TelegramEdit", "TelegramDelete"} {
+		tc.On("ExecuteWorkflow", mock.Anything, mock.Anything, workflowType, mock.Anything).
+			Run(func(args mock.Arguments) {
+				started.ids = append(started.ids, args.Get(1).(client.StartWorkflowOptions).ID)
+			}).
+			Return(run, nil).
+			Maybe()
+	}
+
+	cfg := config.Config{
+		TelegramPageSize:     2,
+		TelegramMaxPages:     10,
+		TemporalTaskQueue:    "tg-bridge",
+		TemporalWorkflowType: "TelegramMessage",
+	}
+	for _, f := range configure {
+		f(&cfg)
+	}
+	publisher, err := temporalpub.NewPublisher(cfg, nil, tc)
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	ch, err := tgclient.NewChannel(ctx, api, "@"+channel.Username, water, db)
+	if err != nil {
+		t.Fatalf("NewChannel: %v", err)
+	}
+	b := New(cfg, db, publisher, metricsserver.New(":0"), nil)
+	b.AddChannel(water, channel.Username, ch)
+	return b, started
+}
+
+// Test_PollFromFakeTelegram polls a channel served by the fake Telegram API and checks that each new
+// message starts its workflow once and the offset follows published messages.
+func Test_PollFromFakeTelegram(t *testing.T) {
+	ctx := context.Background()
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+	api := tgfake.New()
+	api.AddChannel(channel, nil, posts(1, 2, 3)...)
+	b, started := newTestBridge(t, api, channel)
+	db := b.db
+
+	// a new channel starts from its latest page
+	b.Poll(ctx)
+	assertStarted(t, started.ids, "tg:100:2", "tg:100:3")
+
+	// messages posted since are caught up page by page, a failed fetch is retried on the next poll
+	api.Post(channel.ID, posts(4, 5, 6, 7, 8)...)
+	api.Fail(&tg.MessagesGetHistoryRequest{}, tgfake.Error(500, "INTERNAL"))
+	b.Poll(ctx)
+	b.Poll(ctx)
+	assertStarted(t, started.ids, "tg:100:2", "tg:100:3", "tg:100:4", "tg:100:5", "tg:100:6", "tg:100:7", "tg:100:8")
+
+	offset, err := db.GetLastMessageID(domain.ChatID(channel.ID))
+	if err != nil {
+		t.Fatalf("GetLastMessageID: %v", err)
+	}
+	if offset != 8 {
+		t.Fatalf("offset = %d, want 8", offset)
+	}
+
+	// nothing new, nothing is published
+	b.Poll(ctx)
+	assertStarted(t, started.ids, "tg:100:2", "tg:100:3", "tg:100:4", "tg:100:5", "tg:100:6", "tg:100:7", "tg:100:8")
+}
+
+// Test_PushedMessageCoveredByPoll checks that a message pushed by updates while a poll fetched it
+// from history is not published again.
+func Test_PushedMessageCoveredByPoll(t *testing.T) {
+	ctx := context.Background()
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+	api := tgfake.New()
+	api.AddChannel(channel, nil, posts(1, 2, 3)...)
+	b, started := newTestBridge(t, api, channel)
+
+	b.Poll(ctx)
+	assertStarted(t, started.ids, "tg:100:2", "tg:100:3")
+
+	pushed := func(id domain.MessageID) domain.Message {
+		return domain.Message{ID: id, ChatID: domain.ChatID(channel.ID), Text: "outage"}
+	}
+	b.publishPushed(ctx, pushed(3))
+	assertStarted(t, started.ids, "tg:100:2", "tg:100:3")
+
+	b.publishPushed(ctx, pushed(4))
+	assertStarted(t, started.ids, "tg:100:2", "tg:100:3", "tg:100:4")
+}
+
+// Test_BackfillResolvesReplies backfills a reply to a message outside of the range and checks that
+// the replied text is published with it.
+func Test_BackfillResolvesReplies(t *testing.T) {
+	ctx := context.Background()
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+	reply := &tg.Message{ID: 2, Message: "restored", Date: 1700000002}
+	reply.SetReplyTo(&tg.MessageReplyHeader{ReplyToMsgID: 1})
+	api := tgfake.New()
+	api.AddChannel(channel, nil, &tg.Message{ID: 1, Message: "outage on Main st", Date: 1700000001}, reply)
+	b, started := newTestBridge(t, api, channel)
+	b.cfg.TelegramResolveReplies = true
+	// the backfill command doesn't register the channel, it is only passed to Backfill
+	delete(b.channels, domain.Supplier{Type: "water"})
+
+	ch, err := tgclient.NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	if err != nil {
+		t.Fatalf("NewChannel: %v", err)
+	}
+	if err := b.Backfill(ctx, domain.Supplier{Type: "water"}, ch, domain.HistoryRange{FromID: 2, ToID: 2}); err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	published := started.msgs
+	if len(published) != 1 || published[0].ReplyTo == nil || published[0].ReplyTo.Text != "outage on Main st" {
+		t.Fatalf("published = %+v, want reply to 1 with its text", published)
+	}
+}
+
+func albumPart(id int, text string) *tg.Message {
+	m := &tg.Message{ID: id, Message: text, Date: 1700000000 + id}
+	m.SetGroupedID(42)
+	return m
+}
+
+// Test_AlbumPartDeletedAndEdited checks that parts of a published album other than the first one
+// are verified for deletions and resolve to the album when edited.
+func Test_AlbumPartDeletedAndEdited(t *testing.T) {
+	ctx := context.Background()
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+	api := tgfake.New()
+	api.AddChannel(channel, nil, albumPart(1, "outage"), albumPart(2, ""), albumPart(3, ""))
+	b, started := newTestBridge(t, api, channel, func(cfg *config.Config) {
+		cfg.TelegramPageSize = 10
+		cfg.TelegramDeleteWindow = 10
+		cfg.TemporalEditWorkflowType = "TelegramEdit"
+		cfg.TemporalDeleteWorkflowType = "TelegramDelete"
+	})
+
+	b.Poll(ctx)
+	assertStarted(t, started.ids, "tg:100:1")
+
+	edited := albumPart(3, "")
+	edited.SetEditDate(1700000100)
+	api.Delete(channel.ID, 3)
+	api.Post(channel.ID, edited)
+	editDate := time.Unix(1700000100, 0)
+	pushed := domain.Message{ID: 3, ChatID: domain.ChatID(channel.ID), GroupedID: 42, EditDate: &editDate}
+	b.PublishEdits(ctx, domain.Supplier{Type: "water"}, []domain.Message{pushed})
+	assertStarted(t, started.ids, "tg:100:1", "tg:100:1:edit:1700000100")
+
+	api.Delete(channel.ID, 2)
+	b.Poll(ctx)
+	assertStarted(t, started.ids, "tg:100:1", "tg:100:1:edit:1700000100", "tg:100:2:delete")
+}
+
+func assertStarted(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("started workflows = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("started workflows = %v, want %v", got, want)
+		}
+	}
+}
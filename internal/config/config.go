@@ -3,27 +3,57 @@ package config
 import (
 	"log"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"tg-bridge/internal/domain"
-	"tg-bridge/internal/tgclient"
 )
 
 type Config struct {
-	PostgresConnectionString string
-	TelegramApiId            int
-	TelegramApiHash          string
-	TelegramChannels         map[domain.Supplier]string
-	TelegramChannelsSession  map[domain.Supplier]tgclient.Channel
-	TelegramFetchInterval    int
-	TelegramPageSize         int
-	TelegramSession          string
-	TemporalHostPort         string
-	TemporalNamespace        string
-	TemporalTaskQueue        string
-	TemporalWorkflowType     string
-	HttpPort                 int
-	MetricsPort              int
+	PostgresConnectionString   string
+	TelegramApiId              int
+	TelegramApiHash            string
+	TelegramChannels           map[domain.Supplier]string
+	TelegramFetchInterval      int
+	TelegramPageSize           int
+	TelegramMaxPages           int
+	TelegramEditWindow         int
+	TelegramDeleteWindow       int
+	TelegramAlbumWait          int
+	TelegramEngagementWindow   int
+	TelegramEngagementInterval int
+	TelegramSessions           []string
+	TelegramFailoverFloodWaits int
+	TelegramProxy              string
+	TelegramRecordFile         string
+	TelegramTestDC             bool
+	TelegramUpdates            bool
+	TelegramResolveReplies     bool
+	TelegramComments           []string
+	TelegramCommentsWindow     int
+	TelegramTextFormats        []string
+	TelegramFloodWaitMax       int
+	TelegramFloodWaitRetries   int
+	TelegramRateLimits         map[string]float64
+	TemporalHostPort           string
+	TemporalNamespace          string
+	TemporalTaskQueue          string
+	TemporalWorkflowType       string
+	TemporalEditWorkflowType   string
+	TemporalDeleteWorkflowType string
+	TemporalEventWorkflowType  string
+	TemporalPinnedWorkflowType string
+	MediaStore                 string
+	MediaDir                   string
+	MediaMaxSize               int64
+	S3Endpoint                 string
+	S3Bucket                   string
+	S3Region                   string
+	S3AccessKey                string
+	S3SecretKey                string
+	S3UseSSL                   bool
+	HttpPort                   int
+	MetricsPort                int
 }
 
 func LoadConfig() Config {
@@ -40,13 +70,23 @@ func CheckConfigFields(config Config) {
 		len(config.TelegramChannels) == 0 ||
 		config.TelegramFetchInterval == 0 ||
 		config.TelegramPageSize == 0 ||
-		config.TelegramSession == "" ||
+		len(config.TelegramSessions) == 0 ||
 		config.TemporalHostPort == "" ||
 		config.TemporalNamespace == "" ||
 		config.TemporalTaskQueue == "" ||
 		config.TemporalWorkflowType == "" {
 		log.Fatalf("One or more environment variables are missing.")
 	}
+	for _, format := range config.TelegramTextFormats {
+		if format != "markdown" && format != "html" {
+			log.Fatalf("Unknown text format %q, supported formats are markdown and html.", format)
+		}
+	}
+	for _, supplierType := range config.TelegramComments {
+		if _, ok := config.TelegramChannels[domain.Supplier{Type: supplierType}]; !ok {
+			log.Fatalf("Comments are enabled for %q supplier, which has no channel configured.", supplierType)
+		}
+	}
 }
 
 func InitConfig() Config {
@@ -66,24 +106,118 @@ func InitConfig() Config {
 		telegramPageSize = 25
 	}
 
+	telegramMaxPages, err := strconv.Atoi(os.Getenv("TELEGRAM_MAX_PAGES"))
+	if err != nil {
+		telegramMaxPages = 40
+	}
+
 	telegramFetchInterval, _ := strconv.Atoi(os.Getenv("TELEGRAM_FETCH_INTERVAL"))
 	if telegramFetchInterval == 0 {
 		telegramFetchInterval = 60
 	}
+	telegramEditWindow, err := strconv.Atoi(os.Getenv("TELEGRAM_EDIT_WINDOW"))
+	if err != nil {
+		telegramEditWindow = 20
+	}
+
+	telegramDeleteWindow, err := strconv.Atoi(os.Getenv("TELEGRAM_DELETE_WINDOW"))
+	if err != nil {
+		telegramDeleteWindow = 20
+	}
+
+	telegramAlbumWait, err := strconv.Atoi(os.Getenv("TELEGRAM_ALBUM_WAIT"))
+	if err != nil {
+		telegramAlbumWait = 10
+	}
+
+	telegramEngagementWindow, _ := strconv.Atoi(os.Getenv("TELEGRAM_ENGAGEMENT_WINDOW"))
+
+	telegramEngagementInterval, _ := strconv.Atoi(os.Getenv("TELEGRAM_ENGAGEMENT_INTERVAL"))
+	if telegramEngagementInterval == 0 {
+		telegramEngagementInterval = 600
+	}
+
+	mediaMaxSize, err := strconv.ParseInt(os.Getenv("MEDIA_MAX_SIZE"), 10, 64)
+	if err != nil {
+		mediaMaxSize = 20 << 20
+	}
+
+	s3UseSSL, err := strconv.ParseBool(os.Getenv("S3_USE_SSL"))
+	if err != nil {
+		s3UseSSL = true
+	}
+
+	telegramUpdates, _ := strconv.ParseBool(os.Getenv("TELEGRAM_UPDATES"))
+
+	telegramResolveReplies, _ := strconv.ParseBool(os.Getenv("TELEGRAM_RESOLVE_REPLIES"))
+
+	telegramTestDC, _ := strconv.ParseBool(os.Getenv("TELEGRAM_TEST_DC"))
+
+	telegramCommentsWindow, err := strconv.Atoi(os.Getenv("TELEGRAM_COMMENTS_WINDOW"))
+	if err != nil {
+		telegramCommentsWindow = 20
+	}
+
+	telegramFailoverFloodWaits, err := strconv.Atoi(os.Getenv("TELEGRAM_FAILOVER_FLOOD_WAITS"))
+	if err != nil {
+		telegramFailoverFloodWaits = 3
+	}
+
+	telegramFloodWaitMax, err := strconv.Atoi(os.Getenv("TELEGRAM_FLOOD_WAIT_MAX"))
+	if err != nil {
+		telegramFloodWaitMax = 30
+	}
+
+	telegramFloodWaitRetries, err := strconv.Atoi(os.Getenv("TELEGRAM_FLOOD_WAIT_RETRIES"))
+	if err != nil {
+		telegramFloodWaitRetries = 3
+	}
+
 	config := Config{
-		PostgresConnectionString: os.Getenv("POSTGRES_CONNECTION_STRING"),
-		TelegramApiId:            telegramApiId,
-		TelegramApiHash:          os.Getenv("TELEGRAM_API_HASH"),
-		TelegramChannels:         parseChannel(os.Getenv("TELEGRAM_CHANNELS")),
-		TelegramFetchInterval:    telegramFetchInterval,
-		TelegramPageSize:         telegramPageSize,
-		TelegramSession:          os.Getenv("TELEGRAM_SESSION"),
-		TemporalHostPort:         os.Getenv("TEMPORAL_HOST_PORT"),
-		TemporalNamespace:        os.Getenv("TEMPORAL_NAMESPACE"),
-		TemporalTaskQueue:        os.Getenv("TEMPORAL_TASK_QUEUE"),
-		TemporalWorkflowType:     os.Getenv("TEMPORAL_WORKFLOW_TYPE"),
-		HttpPort:                 port,
-		MetricsPort:              metricsPort,
+		PostgresConnectionString:   os.Getenv("POSTGRES_CONNECTION_STRING"),
+		TelegramApiId:              telegramApiId,
+		TelegramApiHash:            os.Getenv("TELEGRAM_API_HASH"),
+		TelegramChannels:           parseChannel(os.Getenv("TELEGRAM_CHANNELS")),
+		TelegramFetchInterval:      telegramFetchInterval,
+		TelegramPageSize:           telegramPageSize,
+		TelegramMaxPages:           telegramMaxPages,
+		TelegramSessions:           parseSessions(os.Getenv("TELEGRAM_SESSION"), os.Getenv("TELEGRAM_SESSIONS")),
+		TelegramFailoverFloodWaits: telegramFailoverFloodWaits,
+		TelegramProxy:              os.Getenv("TELEGRAM_PROXY"),
+		TelegramRecordFile:         os.Getenv("TELEGRAM_RECORD_FILE"),
+		TelegramTestDC:             telegramTestDC,
+		TelegramUpdates:            telegramUpdates,
+		TelegramResolveReplies:     telegramResolveReplies,
+		TelegramComments:           parseList(os.Getenv("TELEGRAM_COMMENTS")),
+		TelegramCommentsWindow:     telegramCommentsWindow,
+		TelegramTextFormats:        parseList(os.Getenv("TELEGRAM_TEXT_FORMATS")),
+		TelegramFloodWaitMax:       telegramFloodWaitMax,
+		TelegramFloodWaitRetries:   telegramFloodWaitRetries,
+		TelegramRateLimits:         parseRateLimits(os.Getenv("TELEGRAM_RATE_LIMITS")),
+		TemporalHostPort:           os.Getenv("TEMPORAL_HOST_PORT"),
+		TemporalNamespace:          os.Getenv("TEMPORAL_NAMESPACE"),
+		TemporalTaskQueue:          os.Getenv("TEMPORAL_TASK_QUEUE"),
+		TemporalWorkflowType:       os.Getenv("TEMPORAL_WORKFLOW_TYPE"),
+		TemporalEditWorkflowType:   os.Getenv("TEMPORAL_EDIT_WORKFLOW_TYPE"),
+		TemporalDeleteWorkflowType: os.Getenv("TEMPORAL_DELETE_WORKFLOW_TYPE"),
+		TemporalEventWorkflowType:  os.Getenv("TEMPORAL_EVENT_WORKFLOW_TYPE"),
+		TemporalPinnedWorkflowType: os.Getenv("TEMPORAL_PINNED_WORKFLOW_TYPE"),
+		TelegramEditWindow:         telegramEditWindow,
+		TelegramDeleteWindow:       telegramDeleteWindow,
+		TelegramAlbumWait:          telegramAlbumWait,
+		TelegramEngagementWindow:   telegramEngagementWindow,
+		TelegramEngagementInterval: telegramEngagementInterval,
+		MediaStore:                 os.Getenv("MEDIA_STORE"),
+		MediaDir:                   os.Getenv("MEDIA_DIR"),
+		MediaMaxSize:               mediaMaxSize,
+		S3Endpoint:                 os.Getenv("S3_ENDPOINT"),
+		S3Bucket:                   os.Getenv("S3_BUCKET"),
+		S3Region:                   os.Getenv("S3_REGION"),
+		S3AccessKey:                os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:                os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:                   s3UseSSL,
+		HttpPort:                   port,
+		MetricsPort:                metricsPort,
 	}
 
 	return config
@@ -114,3 +248,46 @@ func parseChannel(channel string) map[domain.Supplier]string {
 	}
 	return result
 }
+
+func parseList(list string) []string {
+	var result []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// parseSessions returns the single session followed by the comma separated list of sessions,
+// base64 encoded sessions never contain commas.
+func parseSessions(single string, list string) []string {
+	var result []string
+	if single = strings.TrimSpace(single); single != "" {
+		result = append(result, single)
+	}
+	for _, s := range parseList(list) {
+		if !slices.Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// parseRateLimits parses comma separated class=requests per second pairs, e.g. resolve=0.1,history=5.
+func parseRateLimits(limits string) map[string]float64 {
+	result := make(map[string]float64)
+	for _, p := range parseList(limits) {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			log.Fatalf("Invalid rate limit %q, expected class=requests per second.", p)
+		}
+		limit, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			log.Fatalf("Invalid rate limit %q: %v", p, err)
+		}
+		result[strings.TrimSpace(kv[0])] = limit
+	}
+	return result
+}
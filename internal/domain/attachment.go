@@ -0,0 +1,35 @@
+package domain
+
+type AttachmentKind string
+
+const (
+	AttachmentPhoto    AttachmentKind = "photo"
+	AttachmentDocument AttachmentKind = "document"
+	AttachmentWebPage  AttachmentKind = "webpage"
+)
+
+// FileReference is everything needed to download the file from Telegram later.
+type FileReference struct {
+	ID            int64  `json:"id"`
+	AccessHash    int64  `json:"access_hash"`
+	FileReference []byte `json:"file_reference"`
+	DCID          int    `json:"dc_id"`
+	// ThumbSize is the photo size type to download, empty for documents
+	ThumbSize string `json:"thumb_size,omitempty"`
+}
+
+type Attachment struct {
+	Kind     AttachmentKind `json:"kind"`
+	MimeType string         `json:"mime_type,omitempty"`
+	Size     int64          `json:"size,omitempty"`
+	FileName string         `json:"file_name,omitempty"`
+	Width    int            `json:"width,omitempty"`
+	Height   int            `json:"height,omitempty"`
+	// URL and Title are set for web page previews
+	URL   string         `json:"url,omitempty"`
+	Title string         `json:"title,omitempty"`
+	File  *FileReference `json:"file,omitempty"`
+	// BlobURI points to the downloaded file, SHA256 is its content hash
+	BlobURI string `json:"blob_uri,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryRange limits backfilled history by message ids and dates, zero bounds are open.
+// Both bounds are inclusive.
+type HistoryRange struct {
+	FromID MessageID
+	ToID   MessageID
+	From   time.Time
+	To     time.Time
+}
+
+// Contains reports whether the message is within the range.
+func (r HistoryRange) Contains(id MessageID, date time.Time) bool {
+	return (r.FromID == 0 || id >= r.FromID) &&
+		(r.ToID == 0 || id <= r.ToID) &&
+		(r.From.IsZero() || !date.Before(r.From)) &&
+		(r.To.IsZero() || !date.After(r.To))
+}
+
+// Key identifies the range in checkpoints, a backfill of another range starts over.
+func (r HistoryRange) Key() string {
+	return fmt.Sprintf("ids:%d-%d,dates:%s-%s", r.FromID, r.ToID, unixOrEmpty(r.From), unixOrEmpty(r.To))
+}
+
+func unixOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(t.Unix())
+}
+
+// BackfillCheckpoint is the progress of a backfill, history is walked from the newest message backwards.
+type BackfillCheckpoint struct {
+	ChatID ChatID
+	Range  string
+	// Cursor is the oldest published message, the walk continues from messages before it
+	Cursor    MessageID
+	Done      bool
+	UpdatedAt time.Time
+}
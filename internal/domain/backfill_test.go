@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryRange_Contains(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	r := HistoryRange{FromID: 10, ToID: 20, From: jan, To: feb}
+
+	assert.True(t, r.Contains(10, jan))
+	assert.True(t, r.Contains(20, feb))
+	assert.False(t, r.Contains(9, jan.Add(time.Hour)))
+	assert.False(t, r.Contains(21, jan.Add(time.Hour)))
+	assert.False(t, r.Contains(15, jan.Add(-time.Second)))
+	assert.False(t, r.Contains(15, feb.Add(time.Second)))
+	assert.True(t, HistoryRange{}.Contains(1, jan))
+
+	// a range with other dates is backfilled separately
+	assert.NotEqual(t, r.Key(), HistoryRange{FromID: 10, ToID: 20}.Key())
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Engagement is a snapshot of how widely a message was seen.
+type Engagement struct {
+	ChatID     ChatID    `json:"chat_id"`
+	MessageID  MessageID `json:"message_id"`
+	Views      int       `json:"views"`
+	Forwards   int       `json:"forwards"`
+	Reactions  int       `json:"reactions"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// EngagementOf takes a snapshot of the message counters.
+func EngagementOf(m Message, capturedAt time.Time) Engagement {
+	return Engagement{
+		ChatID:     m.ChatID,
+		MessageID:  m.ID,
+		Views:      m.Views,
+		Forwards:   m.Forwards,
+		Reactions:  m.Reactions,
+		CapturedAt: capturedAt,
+	}
+}
@@ -0,0 +1,234 @@
+package domain
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+)
+
+type EntityKind string
+
+const (
+	EntityMention     EntityKind = "mention"
+	EntityHashtag     EntityKind = "hashtag"
+	EntityCashtag     EntityKind = "cashtag"
+	EntityBotCommand  EntityKind = "bot_command"
+	EntityURL         EntityKind = "url"
+	EntityEmail       EntityKind = "email"
+	EntityPhone       EntityKind = "phone"
+	EntityBankCard    EntityKind = "bank_card"
+	EntityTextURL     EntityKind = "text_url"
+	EntityMentionName EntityKind = "mention_name"
+	EntityBold        EntityKind = "bold"
+	EntityItalic      EntityKind = "italic"
+	EntityUnderline   EntityKind = "underline"
+	EntityStrike      EntityKind = "strike"
+	EntitySpoiler     EntityKind = "spoiler"
+	EntityCode        EntityKind = "code"
+	EntityPre         EntityKind = "pre"
+	EntityBlockquote  EntityKind = "blockquote"
+	EntityCustomEmoji EntityKind = "custom_emoji"
+)
+
+// Entity marks a part of message text. Offset and Length are in bytes of Text,
+// so the marked part is Text[Offset:Offset+Length].
+type Entity struct {
+	Kind   EntityKind `json:"kind"`
+	Offset int        `json:"offset"`
+	Length int        `json:"length"`
+	// URL is the link target for url and text_url entities
+	URL string `json:"url,omitempty"`
+	// UserID is the mentioned user for mention_name entities
+	UserID UserID `json:"user_id,omitempty"`
+	// Language is set for pre entities
+	Language string `json:"language,omitempty"`
+}
+
+func (e Entity) end() int {
+	return e.Offset + e.Length
+}
+
+// RenderMarkdown renders text with its formatting entities as Markdown.
+func RenderMarkdown(text string, entities []Entity) string {
+	return render(text, entities, markdownFormat{})
+}
+
+// RenderHTML renders text with its formatting entities as HTML.
+func RenderHTML(text string, entities []Entity) string {
+	return render(text, entities, htmlFormat{})
+}
+
+type textFormat interface {
+	open(e Entity) string
+	close(e Entity) string
+	escape(s string, open []Entity) string
+}
+
+// render wraps entity parts of the text in format markup. Telegram allows entities
+// to overlap, such entities are closed and reopened so the markup stays well nested.
+func render(text string, entities []Entity, f textFormat) string {
+	sorted := make([]Entity, 0, len(entities))
+	points := []int{len(text)}
+	for _, e := range entities {
+		if e.Length <= 0 || e.Offset < 0 || e.end() > len(text) {
+			continue
+		}
+		sorted = append(sorted, e)
+		points = append(points, e.Offset, e.end())
+	}
+	slices.SortStableFunc(sorted, func(a, b Entity) int {
+		if a.Offset != b.Offset {
+			return a.Offset - b.Offset
+		}
+		return b.Length - a.Length
+	})
+	slices.Sort(points)
+	points = slices.Compact(points)
+
+	var (
+		b     strings.Builder
+		stack []Entity
+		pos   int
+		next  int
+	)
+	for _, p := range points {
+		b.WriteString(f.escape(text[pos:p], stack))
+		pos = p
+
+		// close entities ending here, reopening the ones nested in them which go on
+		var reopen []Entity
+		for slices.ContainsFunc(stack, func(e Entity) bool { return e.end() <= p }) {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			b.WriteString(f.close(top))
+			if top.end() > p {
+				reopen = append(reopen, top)
+			}
+		}
+		for i := len(reopen) - 1; i >= 0; i-- {
+			b.WriteString(f.open(reopen[i]))
+			stack = append(stack, reopen[i])
+		}
+
+		for next < len(sorted) && sorted[next].Offset == p {
+			b.WriteString(f.open(sorted[next]))
+			stack = append(stack, sorted[next])
+			next++
+		}
+	}
+	return b.String()
+}
+
+type markdownFormat struct{}
+
+func (markdownFormat) open(e Entity) string {
+	switch e.Kind {
+	case EntityBold:
+		return "**"
+	case EntityItalic:
+		return "_"
+	case EntityStrike:
+		return "~~"
+	case EntityCode:
+		return "`"
+	case EntityPre:
+		return "```" + e.Language + "\n"
+	case EntityTextURL, EntityMentionName:
+		return "["
+	}
+	return ""
+}
+
+func (markdownFormat) close(e Entity) string {
+	switch e.Kind {
+	case EntityBold:
+		return "**"
+	case EntityItalic:
+		return "_"
+	case EntityStrike:
+		return "~~"
+	case EntityCode:
+		return "`"
+	case EntityPre:
+		return "\n```"
+	case EntityTextURL:
+		return "](" + e.URL + ")"
+	case EntityMentionName:
+		return fmt.Sprintf("](tg://user?id=%d)", e.UserID)
+	}
+	return ""
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `~`, `\~`, `[`, `\[`, `]`, `\]`,
+)
+
+func (markdownFormat) escape(s string, open []Entity) string {
+	if slices.ContainsFunc(open, func(e Entity) bool { return e.Kind == EntityCode || e.Kind == EntityPre }) {
+		return s
+	}
+	return markdownEscaper.Replace(s)
+}
+
+type htmlFormat struct{}
+
+func (htmlFormat) open(e Entity) string {
+	switch e.Kind {
+	case EntityBold:
+		return "<b>"
+	case EntityItalic:
+		return "<i>"
+	case EntityUnderline:
+		return "<u>"
+	case EntityStrike:
+		return "<s>"
+	case EntitySpoiler:
+		return "<tg-spoiler>"
+	case EntityCode:
+		return "<code>"
+	case EntityPre:
+		if e.Language != "" {
+			return fmt.Sprintf(`<pre><code class="language-%s">`, html.EscapeString(e.Language))
+		}
+		return "<pre>"
+	case EntityBlockquote:
+		return "<blockquote>"
+	case EntityURL, EntityTextURL:
+		return fmt.Sprintf(`<a href="%s">`, html.EscapeString(e.URL))
+	case EntityMentionName:
+		return fmt.Sprintf(`<a href="tg://user?id=%d">`, e.UserID)
+	}
+	return ""
+}
+
+func (htmlFormat) close(e Entity) string {
+	switch e.Kind {
+	case EntityBold:
+		return "</b>"
+	case EntityItalic:
+		return "</i>"
+	case EntityUnderline:
+		return "</u>"
+	case EntityStrike:
+		return "</s>"
+	case EntitySpoiler:
+		return "</tg-spoiler>"
+	case EntityCode:
+		return "</code>"
+	case EntityPre:
+		if e.Language != "" {
+			return "</code></pre>"
+		}
+		return "</pre>"
+	case EntityBlockquote:
+		return "</blockquote>"
+	case EntityURL, EntityTextURL, EntityMentionName:
+		return "</a>"
+	}
+	return ""
+}
+
+func (htmlFormat) escape(s string, _ []Entity) string {
+	return html.EscapeString(s)
+}
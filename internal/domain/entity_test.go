@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []Entity
+		markdown string
+		html     string
+	}{
+		{
+			name:     "plain",
+			text:     "no water <today> *",
+			markdown: `no water <today> \*`,
+			html:     "no water &lt;today&gt; *",
+		},
+		{
+			name: "link_and_bold",
+			text: "Отключение завтра, подробнее",
+			entities: []Entity{
+				{Kind: EntityBold, Offset: 0, Length: 20},
+				{Kind: EntityTextURL, Offset: 35, Length: 18, URL: "https://example.com/a?b=1&c=2"},
+			},
+			markdown: "**Отключение** завтра, [подробнее](https://example.com/a?b=1&c=2)",
+			html:     `<b>Отключение</b> завтра, <a href="https://example.com/a?b=1&amp;c=2">подробнее</a>`,
+		},
+		{
+			name: "nested",
+			text: "bold italic",
+			entities: []Entity{
+				{Kind: EntityBold, Offset: 0, Length: 11},
+				{Kind: EntityItalic, Offset: 5, Length: 6},
+			},
+			markdown: "**bold _italic_**",
+			html:     "<b>bold <i>italic</i></b>",
+		},
+		{
+			name: "overlapping",
+			text: "abcdef",
+			entities: []Entity{
+				{Kind: EntityBold, Offset: 0, Length: 4},
+				{Kind: EntityItalic, Offset: 2, Length: 4},
+			},
+			markdown: "**ab_cd_**_ef_",
+			html:     "<b>ab<i>cd</i></b><i>ef</i>",
+		},
+		{
+			name: "code_is_not_escaped",
+			text: "run a_b*c",
+			entities: []Entity{
+				{Kind: EntityPre, Offset: 4, Length: 5, Language: "sh"},
+			},
+			markdown: "run ```sh\na_b*c\n```",
+			html:     `run <pre><code class="language-sh">a_b*c</code></pre>`,
+		},
+		{
+			name: "mention_and_hashtag",
+			text: "ask Ivan #water",
+			entities: []Entity{
+				{Kind: EntityMentionName, Offset: 4, Length: 4, UserID: 42},
+				{Kind: EntityHashtag, Offset: 9, Length: 6},
+			},
+			markdown: "ask [Ivan](tg://user?id=42) #water",
+			html:     `ask <a href="tg://user?id=42">Ivan</a> #water`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.markdown, RenderMarkdown(tt.text, tt.entities))
+			assert.Equal(t, tt.html, RenderHTML(tt.text, tt.entities))
+		})
+	}
+}
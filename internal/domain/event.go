@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventPinMessage     EventKind = "pin_message"
+	EventTitleChanged   EventKind = "title_changed"
+	EventPhotoChanged   EventKind = "photo_changed"
+	EventPhotoDeleted   EventKind = "photo_deleted"
+	EventChannelCreated EventKind = "channel_created"
+	EventMigratedFrom   EventKind = "migrated_from"
+	EventMigratedTo     EventKind = "migrated_to"
+	EventTopicCreated   EventKind = "topic_created"
+	EventTopicEdited    EventKind = "topic_edited"
+	// EventOther is any other action, Action keeps its Telegram name
+	EventOther EventKind = "other"
+)
+
+// ServiceEvent is an action taken in a chat, such as pinning a message or changing its title.
+// Telegram posts these as service messages, so the event shares message ids with the chat.
+type ServiceEvent struct {
+	ID      MessageID      `json:"id"`
+	ChatID  ChatID         `json:"chat_id"`
+	From    User           `json:"from"`
+	Date    time.Time      `json:"date"`
+	Kind    EventKind      `json:"kind"`
+	Context map[string]any `json:"context,omitempty"`
+	// Action is the Telegram name of the action, e.g. messageActionPinMessage
+	Action string `json:"action"`
+	// PinnedID is the pinned message for pin_message
+	PinnedID MessageID `json:"pinned_id,omitempty"`
+	// Title is the new title of the chat or topic
+	Title string `json:"title,omitempty"`
+	// Photo is the new photo for photo_changed
+	Photo *Attachment `json:"photo,omitempty"`
+	// MigratedChatID is the other side of migration of a basic group to a supergroup
+	MigratedChatID ChatID `json:"migrated_chat_id,omitempty"`
+	// TopicID is the forum topic the action was taken in
+	TopicID int `json:"topic_id,omitempty"`
+}
+
+func (e ServiceEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
@@ -24,6 +24,21 @@ type Supplier struct {
 type MessageRef struct {
 	ID     MessageID `json:"id"`
 	ChatID ChatID    `json:"chat_id"`
+	// Text of the replied message, only when replies are resolved and the message still exists
+	Text string `json:"text,omitempty"`
+}
+
+// ForwardOrigin describes the original message of a forwarded one.
+type ForwardOrigin struct {
+	// ChatID or UserID is the original sender, neither is set when the sender hides the account
+	ChatID ChatID `json:"chat_id,omitempty"`
+	UserID UserID `json:"user_id,omitempty"`
+	// FromName is the name of a sender hiding the account
+	FromName string `json:"from_name,omitempty"`
+	// MessageID is the original message, only known for messages forwarded from channels
+	MessageID  MessageID `json:"message_id,omitempty"`
+	Date       time.Time `json:"date"`
+	PostAuthor string    `json:"post_author,omitempty"`
 }
 
 type Message struct {
@@ -34,6 +49,53 @@ type Message struct {
 	Date    time.Time      `json:"date"`
 	ReplyTo *MessageRef    `json:"reply_to,omitempty"`
 	Context map[string]any `json:"context,omitempty"`
+	// CommentOf is the channel post a comment from its discussion group belongs to
+	CommentOf *MessageRef `json:"comment_of,omitempty"`
+	// ForwardedFrom is set for forwarded messages
+	ForwardedFrom *ForwardOrigin `json:"forwarded_from,omitempty"`
+	// TopicID is the forum topic of the message, zero outside of forums
+	TopicID int `json:"topic_id,omitempty"`
+	// EditDate is set when the message was edited after posting
+	EditDate    *time.Time   `json:"edit_date,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Entities    []Entity     `json:"entities,omitempty"`
+	// Markdown and HTML are Text rendered with its entities, only when enabled
+	Markdown string `json:"markdown,omitempty"`
+	HTML     string `json:"html,omitempty"`
+	// GroupedID is set for albums, AlbumIDs are ids of all messages merged into the album
+	GroupedID int64       `json:"grouped_id,omitempty"`
+	AlbumIDs  []MessageID `json:"album_ids,omitempty"`
+	// Geo is set for locations, Venue and Poll for venues and polls
+	Geo   *GeoPoint `json:"geo,omitempty"`
+	Venue *Venue    `json:"venue,omitempty"`
+	Poll  *Poll     `json:"poll,omitempty"`
+	// Views, Forwards and Reactions are counters at the time the message was fetched
+	Views     int `json:"views,omitempty"`
+	Forwards  int `json:"forwards,omitempty"`
+	Reactions int `json:"reactions,omitempty"`
+}
+
+// MessageEdit is a new version of already published message.
+type MessageEdit struct {
+	Message Message `json:"message"`
+	OldText string  `json:"old_text"`
+}
+
+// MessageDeletion is a deletion of already published message.
+type MessageDeletion struct {
+	ID     MessageID `json:"id"`
+	ChatID ChatID    `json:"chat_id"`
+	// DetectedAt is the time the deletion was noticed, Telegram does not report when it happened
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// PinnedChange is a change of the message pinned in a chat.
+type PinnedChange struct {
+	ChatID ChatID `json:"chat_id"`
+	// MessageID is the pinned message, zero when nothing is pinned anymore
+	MessageID  MessageID `json:"message_id"`
+	PreviousID MessageID `json:"previous_id,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
 }
 
 func NewMessage(
@@ -65,6 +127,27 @@ func NewMessage(
 	}, nil
 }
 
+// LastID returns the latest id of the message, which is the last merged one for albums.
+func (m Message) LastID() MessageID {
+	last := m.ID
+	for _, id := range m.AlbumIDs {
+		last = max(last, id)
+	}
+	return last
+}
+
 func (m Message) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
+
+func (e MessageEdit) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (d MessageDeletion) ToJSON() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func (p PinnedChange) ToJSON() ([]byte, error) {
+	return json.Marshal(p)
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+type PeerKind string
+
+const (
+	// PeerChannel is a broadcast channel or a supergroup
+	PeerChannel PeerKind = "channel"
+	// PeerChat is a basic group
+	PeerChat PeerKind = "chat"
+)
+
+// Peer is a resolved chat kept between restarts, so it is not resolved again on every start.
+type Peer struct {
+	ChatID     ChatID
+	AccessHash int64
+	Kind       PeerKind
+	Forum      bool
+	Username   string
+	Title      string
+	ResolvedAt time.Time
+}
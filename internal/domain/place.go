@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// AccuracyRadius is in meters, zero when unknown
+	AccuracyRadius int `json:"accuracy_radius,omitempty"`
+	// Live is set for live locations, which keep moving after the message is posted
+	Live bool `json:"live,omitempty"`
+}
+
+type Venue struct {
+	Geo     GeoPoint `json:"geo"`
+	Title   string   `json:"title"`
+	Address string   `json:"address,omitempty"`
+	// Provider is the venue database, e.g. foursquare, VenueID and VenueType are ids in it
+	Provider  string `json:"provider,omitempty"`
+	VenueID   string `json:"venue_id,omitempty"`
+	VenueType string `json:"venue_type,omitempty"`
+}
+
+type Poll struct {
+	ID             int64        `json:"id"`
+	Question       string       `json:"question"`
+	Answers        []PollAnswer `json:"answers"`
+	Closed         bool         `json:"closed,omitempty"`
+	Quiz           bool         `json:"quiz,omitempty"`
+	MultipleChoice bool         `json:"multiple_choice,omitempty"`
+	PublicVoters   bool         `json:"public_voters,omitempty"`
+	CloseDate      *time.Time   `json:"close_date,omitempty"`
+	// TotalVoters and answer voters are known once the results are visible to the account
+	TotalVoters int `json:"total_voters"`
+}
+
+type PollAnswer struct {
+	Text string `json:"text"`
+	// Option identifies the answer in the poll
+	Option  []byte `json:"option"`
+	Voters  int    `json:"voters"`
+	Correct bool   `json:"correct,omitempty"`
+}
@@ -3,6 +3,7 @@ package metricsserver
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -15,6 +16,15 @@ type Server struct {
 	registry   *prometheus.Registry
 
 	telegramMessages *prometheus.CounterVec
+	telegramComments *prometheus.CounterVec
+	floodWaits       *prometheus.CounterVec
+	floodWaitSeconds *prometheus.CounterVec
+	rateLimitSeconds *prometheus.CounterVec
+	channelRenames   *prometheus.CounterVec
+	failovers        *prometheus.CounterVec
+	recentViews      *prometheus.GaugeVec
+	recentForwards   *prometheus.GaugeVec
+	recentReactions  *prometheus.GaugeVec
 }
 
 func New(addr string) *Server {
@@ -37,12 +47,99 @@ func New(addr string) *Server {
 	)
 	reg.MustRegister(telegramMessages)
 
+	// Comments under channel posts are counted apart from the posts themselves
+	telegramComments := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_channel_comments_total",
+			Help: "Total number of comments received from discussion groups of Telegram channels, labeled by channel username.",
+		},
+		[]string{"channel"},
+	)
+	reg.MustRegister(telegramComments)
+
+	// Channels keep being bridged by id when suppliers change their usernames
+	channelRenames := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_channel_renames_total",
+			Help: "Total number of detected username changes of Telegram channels, labeled by configured channel.",
+		},
+		[]string{"channel"},
+	)
+	reg.MustRegister(channelRenames)
+
+	// Channels move to another account when theirs is logged out, banned or keeps hitting FLOOD_WAIT
+	failovers := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_channel_failovers_total",
+			Help: "Total number of moves of Telegram channels to another account, labeled by configured channel.",
+		},
+		[]string{"channel"},
+	)
+	reg.MustRegister(failovers)
+
+	// Engagement of the latest messages, refreshed by periodic snapshots
+	recentViews := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telegram_channel_recent_views",
+			Help: "Total views of the latest messages of Telegram channels, labeled by channel username.",
+		},
+		[]string{"channel"},
+	)
+	recentForwards := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telegram_channel_recent_forwards",
+			Help: "Total forwards of the latest messages of Telegram channels, labeled by channel username.",
+		},
+		[]string{"channel"},
+	)
+	recentReactions := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telegram_channel_recent_reactions",
+			Help: "Total reactions to the latest messages of Telegram channels, labeled by channel username.",
+		},
+		[]string{"channel"},
+	)
+	reg.MustRegister(recentViews, recentForwards, recentReactions)
+
+	// Telegram API back-off: FLOOD_WAIT errors and delays of the client side rate limiter
+	floodWaits := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_flood_waits_total",
+			Help: "Total number of FLOOD_WAIT errors, labeled by API method.",
+		},
+		[]string{"method"},
+	)
+	floodWaitSeconds := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_flood_wait_seconds_total",
+			Help: "Total seconds Telegram asked to wait by FLOOD_WAIT errors, labeled by API method.",
+		},
+		[]string{"method"},
+	)
+	rateLimitSeconds := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_rate_limit_wait_seconds_total",
+			Help: "Total seconds API calls were delayed by the rate limiter, labeled by method class.",
+		},
+		[]string{"class"},
+	)
+	reg.MustRegister(floodWaits, floodWaitSeconds, rateLimitSeconds)
+
 	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 
 	s := &Server{
 		addr:             addr,
 		registry:         reg,
 		telegramMessages: telegramMessages,
+		telegramComments: telegramComments,
+		floodWaits:       floodWaits,
+		floodWaitSeconds: floodWaitSeconds,
+		rateLimitSeconds: rateLimitSeconds,
+		channelRenames:   channelRenames,
+		failovers:        failovers,
+		recentViews:      recentViews,
+		recentForwards:   recentForwards,
+		recentReactions:  recentReactions,
 		httpServer: &http.Server{
 			Addr:    addr,
 			Handler: mux,
@@ -60,6 +157,43 @@ func (s *Server) AddTelegramChannelMessages(channel string, n int) {
 	s.telegramMessages.WithLabelValues(channel).Add(float64(n))
 }
 
+// AddTelegramChannelFailover counts a move of the channel to another account.
+func (s *Server) AddTelegramChannelFailover(channel string) {
+	s.failovers.WithLabelValues(channel).Inc()
+}
+
+// AddTelegramChannelComments increases the comments counter for a given channel by n.
+// If n <= 0, the call is a no-op.
+func (s *Server) AddTelegramChannelComments(channel string, n int) {
+	if n <= 0 {
+		return
+	}
+	s.telegramComments.WithLabelValues(channel).Add(float64(n))
+}
+
+// SetTelegramChannelEngagement sets engagement gauges of the channel to the totals of its latest messages.
+func (s *Server) SetTelegramChannelEngagement(channel string, views, forwards, reactions int) {
+	s.recentViews.WithLabelValues(channel).Set(float64(views))
+	s.recentForwards.WithLabelValues(channel).Set(float64(forwards))
+	s.recentReactions.WithLabelValues(channel).Set(float64(reactions))
+}
+
+// AddTelegramChannelRename counts a detected username change of the configured channel.
+func (s *Server) AddTelegramChannelRename(channel string) {
+	s.channelRenames.WithLabelValues(channel).Inc()
+}
+
+// ObserveTelegramFloodWait records a FLOOD_WAIT of the API method asking to wait d.
+func (s *Server) ObserveTelegramFloodWait(method string, d time.Duration) {
+	s.floodWaits.WithLabelValues(method).Inc()
+	s.floodWaitSeconds.WithLabelValues(method).Add(d.Seconds())
+}
+
+// ObserveTelegramRateLimitWait records an API call of the method class delayed for d by the rate limiter.
+func (s *Server) ObserveTelegramRateLimitWait(class string, d time.Duration) {
+	s.rateLimitSeconds.WithLabelValues(class).Add(d.Seconds())
+}
+
 func (s *Server) ListenAndServe() error {
 	return s.httpServer.ListenAndServe()
 }
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMetricsEndpoint_ExposesStandardMetrics(t *testing.T) {
@@ -64,3 +65,43 @@ func TestTelegramChannelMessagesCounter(t *testing.T) {
 		t.Fatalf("expected telegram_channel_messages_total counter with value 2 for channel label, got:\n%s", text)
 	}
 }
+
+func TestTelegramClientMetrics(t *testing.T) {
+	s := New(":0")
+
+	s.ObserveTelegramFloodWait("contacts.resolveUsername", 30*time.Second)
+	s.ObserveTelegramFloodWait("contacts.resolveUsername", 15*time.Second)
+	s.ObserveTelegramRateLimitWait("history", 500*time.Millisecond)
+	s.AddTelegramChannelRename("vodokanalpmrcom")
+	s.SetTelegramChannelEngagement("vodokanalpmrcom", 1200, 15, 40)
+	s.AddTelegramChannelComments("vodokanalpmrcom", 3)
+	s.AddTelegramChannelFailover("vodokanalpmrcom")
+
+	ts := httptest.NewServer(s.httpServer.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
+
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	for _, want := range []string{
+		`telegram_flood_waits_total{method="contacts.resolveUsername"} 2`,
+		`telegram_flood_wait_seconds_total{method="contacts.resolveUsername"} 45`,
+		`telegram_rate_limit_wait_seconds_total{class="history"} 0.5`,
+		`telegram_channel_renames_total{channel="vodokanalpmrcom"} 1`,
+		`telegram_channel_recent_views{channel="vodokanalpmrcom"} 1200`,
+		`telegram_channel_recent_forwards{channel="vodokanalpmrcom"} 15`,
+		`telegram_channel_recent_reactions{channel="vodokanalpmrcom"} 40`,
+		`telegram_channel_comments_total{channel="vodokanalpmrcom"} 3`,
+		`telegram_channel_failovers_total{channel="vodokanalpmrcom"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %s, got:\n%s", want, text)
+		}
+	}
+}
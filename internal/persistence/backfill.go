@@ -0,0 +1,50 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveBackfillCheckpoint records progress of the backfill of a history range of the chat.
+func (c *DatabaseConnection) SaveBackfillCheckpoint(cp domain.BackfillCheckpoint) error {
+	ctx := context.Background()
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO backfill_checkpoints (chat_id, range_key, cursor, done, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id, range_key)
+		DO UPDATE SET cursor = EXCLUDED.cursor, done = EXCLUDED.done, updated_at = EXCLUDED.updated_at
+	`, int64(cp.ChatID), cp.Range, int64(cp.Cursor), cp.Done, cp.UpdatedAt)
+	return err
+}
+
+// GetBackfillCheckpoint returns progress of the backfill of the range, false if it was never started.
+func (c *DatabaseConnection) GetBackfillCheckpoint(chat domain.ChatID, rangeKey string) (domain.BackfillCheckpoint, bool, error) {
+	ctx := context.Background()
+	cp := domain.BackfillCheckpoint{ChatID: chat, Range: rangeKey}
+	var cursor int64
+	err := c.pool.QueryRow(ctx, `
+		SELECT cursor, done, updated_at
+		FROM backfill_checkpoints
+		WHERE chat_id = $1 AND range_key = $2
+	`, int64(chat), rangeKey).Scan(&cursor, &cp.Done, &cp.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.BackfillCheckpoint{}, false, nil
+		}
+		return domain.BackfillCheckpoint{}, false, err
+	}
+	cp.Cursor = domain.MessageID(cursor)
+	return cp, true, nil
+}
+
+// DeleteBackfillCheckpoint forgets progress of the range, so the backfill starts over.
+func (c *DatabaseConnection) DeleteBackfillCheckpoint(chat domain.ChatID, rangeKey string) error {
+	ctx := context.Background()
+	_, err := c.pool.Exec(ctx, `
+		DELETE FROM backfill_checkpoints
+		WHERE chat_id = $1 AND range_key = $2
+	`, int64(chat), rangeKey)
+	return err
+}
@@ -0,0 +1,52 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+)
+
+// SaveCommentOffset records the latest published comment of the post, comments of each post
+// have their own offset as they are fetched thread by thread.
+func (c *DatabaseConnection) SaveCommentOffset(chat domain.ChatID, post domain.MessageID, lastComment domain.MessageID) error {
+	ctx := context.Background()
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO comment_offsets (chat_id, post_id, last_comment_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id, post_id)
+		DO UPDATE SET last_comment_id = GREATEST(EXCLUDED.last_comment_id, comment_offsets.last_comment_id)
+	`, int64(chat), int64(post), int64(lastComment))
+	return err
+}
+
+// GetCommentOffsets returns offsets of the given posts of the chat, posts without comments published are absent.
+func (c *DatabaseConnection) GetCommentOffsets(chat domain.ChatID, posts []domain.MessageID) (map[domain.MessageID]domain.MessageID, error) {
+	result := make(map[domain.MessageID]domain.MessageID, len(posts))
+	if len(posts) == 0 {
+		return result, nil
+	}
+
+	ids := make([]int64, 0, len(posts))
+	for _, id := range posts {
+		ids = append(ids, int64(id))
+	}
+
+	ctx := context.Background()
+	rows, err := c.pool.Query(ctx, `
+		SELECT post_id, last_comment_id
+		FROM comment_offsets
+		WHERE chat_id = $1 AND post_id = ANY($2)
+	`, int64(chat), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post, last int64
+		if err := rows.Scan(&post, &last); err != nil {
+			return nil, err
+		}
+		result[domain.MessageID(post)] = domain.MessageID(last)
+	}
+	return result, rows.Err()
+}
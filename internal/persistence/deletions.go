@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveMessageDeletions records deleted messages, repeated deletions are ignored.
+func (c *DatabaseConnection) SaveMessageDeletions(deletions []domain.MessageDeletion) error {
+	if len(deletions) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	const q = `
+		INSERT INTO message_deletions (chat_id, message_id, detected_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id, message_id) DO NOTHING
+	`
+
+	batch := &pgx.Batch{}
+	for _, d := range deletions {
+		batch.Queue(q, int64(d.ChatID), int64(d.ID), d.DetectedAt)
+	}
+
+	br := c.pool.SendBatch(ctx, batch)
+	defer func(br pgx.BatchResults) {
+		_ = br.Close()
+	}(br)
+
+	for range deletions {
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRecentMessageIDs returns up to limit ids of the latest published messages of the chat
+// which are not deleted, newest first.
+func (c *DatabaseConnection) GetRecentMessageIDs(chat domain.ChatID, limit int) ([]domain.MessageID, error) {
+	ctx := context.Background()
+	rows, err := c.pool.Query(ctx, `
+		SELECT v.message_id
+		FROM message_versions v
+		WHERE v.chat_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM message_deletions d
+			WHERE d.chat_id = v.chat_id AND d.message_id = v.message_id
+		  )
+		ORDER BY v.message_id DESC
+		LIMIT $2
+	`, int64(chat), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessageIDs(rows)
+}
+
+// GetLiveMessageIDs returns those of ids which were published and are not deleted yet.
+func (c *DatabaseConnection) GetLiveMessageIDs(chat domain.ChatID, ids []domain.MessageID) ([]domain.MessageID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	ctx := context.Background()
+
+	rawIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		rawIDs = append(rawIDs, int64(id))
+	}
+
+	rows, err := c.pool.Query(ctx, `
+		SELECT v.message_id
+		FROM message_versions v
+		WHERE v.chat_id = $1
+		  AND v.message_id = ANY($2)
+		  AND NOT EXISTS (
+			SELECT 1 FROM message_deletions d
+			WHERE d.chat_id = v.chat_id AND d.message_id = v.message_id
+		  )
+		ORDER BY v.message_id
+	`, int64(chat), rawIDs)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessageIDs(rows)
+}
+
+func scanMessageIDs(rows pgx.Rows) ([]domain.MessageID, error) {
+	defer rows.Close()
+
+	var result []domain.MessageID
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, domain.MessageID(id))
+	}
+	return result, rows.Err()
+}
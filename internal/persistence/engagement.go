@@ -0,0 +1,64 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveEngagement records snapshots of message counters, a snapshot taken at the same time is kept once.
+func (c *DatabaseConnection) SaveEngagement(snapshots []domain.Engagement) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	const q = `
+		INSERT INTO message_engagement (chat_id, message_id, captured_at, views, forwards, reactions)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id, message_id, captured_at) DO NOTHING
+	`
+
+	batch := &pgx.Batch{}
+	for _, e := range snapshots {
+		batch.Queue(q, int64(e.ChatID), int64(e.MessageID), e.CapturedAt, e.Views, e.Forwards, e.Reactions)
+	}
+
+	br := c.pool.SendBatch(ctx, batch)
+	defer func(br pgx.BatchResults) {
+		_ = br.Close()
+	}(br)
+
+	for range snapshots {
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEngagement returns snapshots of the message in the order they were taken.
+func (c *DatabaseConnection) GetEngagement(chat domain.ChatID, message domain.MessageID) ([]domain.Engagement, error) {
+	ctx := context.Background()
+	rows, err := c.pool.Query(ctx, `
+		SELECT captured_at, views, forwards, reactions
+		FROM message_engagement
+		WHERE chat_id = $1 AND message_id = $2
+		ORDER BY captured_at
+	`, int64(chat), int64(message))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.Engagement
+	for rows.Next() {
+		e := domain.Engagement{ChatID: chat, MessageID: message}
+		if err := rows.Scan(&e.CapturedAt, &e.Views, &e.Forwards, &e.Reactions); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
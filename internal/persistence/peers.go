@@ -0,0 +1,58 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// GetResolvedPeer returns the peer cached for the channel reference, if any.
+func (c *DatabaseConnection) GetResolvedPeer(ref string) (domain.Peer, bool, error) {
+	ctx := context.Background()
+	var (
+		peer               domain.Peer
+		chatID, accessHash int64
+		kind               string
+	)
+	err := c.pool.QueryRow(ctx, `
+		SELECT chat_id, access_hash, kind, forum, username, title, resolved_at
+		FROM resolved_peers
+		WHERE ref = $1
+	`, ref).Scan(&chatID, &accessHash, &kind, &peer.Forum, &peer.Username, &peer.Title, &peer.ResolvedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.Peer{}, false, nil
+		}
+		return domain.Peer{}, false, err
+	}
+	peer.ChatID = domain.ChatID(chatID)
+	peer.AccessHash = accessHash
+	peer.Kind = domain.PeerKind(kind)
+	return peer, true, nil
+}
+
+// SaveResolvedPeer caches the peer of the channel reference, replacing the previous one.
+func (c *DatabaseConnection) SaveResolvedPeer(ref string, peer domain.Peer) error {
+	ctx := context.Background()
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO resolved_peers (ref, chat_id, access_hash, kind, forum, username, title, resolved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (ref)
+		DO UPDATE SET chat_id = EXCLUDED.chat_id,
+			access_hash = EXCLUDED.access_hash,
+			kind = EXCLUDED.kind,
+			forum = EXCLUDED.forum,
+			username = EXCLUDED.username,
+			title = EXCLUDED.title,
+			resolved_at = EXCLUDED.resolved_at
+	`, ref, int64(peer.ChatID), peer.AccessHash, string(peer.Kind), peer.Forum, peer.Username, peer.Title, peer.ResolvedAt)
+	return err
+}
+
+// DeleteResolvedPeer drops the cached peer of the channel reference.
+func (c *DatabaseConnection) DeleteResolvedPeer(ref string) error {
+	ctx := context.Background()
+	_, err := c.pool.Exec(ctx, `DELETE FROM resolved_peers WHERE ref = $1`, ref)
+	return err
+}
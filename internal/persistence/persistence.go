@@ -8,6 +8,66 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS last_message_offsets (
+		chat_id NUMERIC PRIMARY KEY,
+		last_message_id NUMERIC NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS message_versions (
+		chat_id NUMERIC NOT NULL,
+		message_id NUMERIC NOT NULL,
+		edit_date BIGINT NOT NULL,
+		text TEXT NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	)`,
+	// every part of an album has a row referencing the first part, zero for other messages
+	`ALTER TABLE message_versions ADD COLUMN IF NOT EXISTS album_id NUMERIC NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS message_deletions (
+		chat_id NUMERIC NOT NULL,
+		message_id NUMERIC NOT NULL,
+		detected_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS resolved_peers (
+		ref TEXT PRIMARY KEY,
+		chat_id NUMERIC NOT NULL,
+		access_hash NUMERIC NOT NULL,
+		kind TEXT NOT NULL,
+		forum BOOLEAN NOT NULL,
+		username TEXT NOT NULL,
+		title TEXT NOT NULL,
+		resolved_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS pinned_messages (
+		chat_id NUMERIC PRIMARY KEY,
+		message_id NUMERIC NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS message_engagement (
+		chat_id NUMERIC NOT NULL,
+		message_id NUMERIC NOT NULL,
+		captured_at TIMESTAMPTZ NOT NULL,
+		views INTEGER NOT NULL,
+		forwards INTEGER NOT NULL,
+		reactions INTEGER NOT NULL,
+		PRIMARY KEY (chat_id, message_id, captured_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS comment_offsets (
+		chat_id NUMERIC NOT NULL,
+		post_id NUMERIC NOT NULL,
+		last_comment_id NUMERIC NOT NULL,
+		PRIMARY KEY (chat_id, post_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS backfill_checkpoints (
+		chat_id NUMERIC NOT NULL,
+		range_key TEXT NOT NULL,
+		cursor NUMERIC NOT NULL,
+		done BOOLEAN NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (chat_id, range_key)
+	)`,
+}
+
 type DatabaseConnection struct {
 	pool *pgxpool.Pool
 }
@@ -19,39 +79,48 @@ func NewDatabase(connString string) (*DatabaseConnection, error) {
 	}
 	db := &DatabaseConnection{pool: pool}
 
-	_, err = db.pool.Exec(
-		context.Background(),
-		`CREATE TABLE IF NOT EXISTS last_message_offsets (
-			chat_id NUMERIC PRIMARY KEY,
-			last_message_id NUMERIC NOT NULL
-		)`,
-	)
-	if err != nil {
-		pool.Close()
-		return nil, err
+	for _, ddl := range schema {
+		if _, err = db.pool.Exec(context.Background(), ddl); err != nil {
+			pool.Close()
+			return nil, err
+		}
 	}
 
 	return db, nil
 }
 
 func (c *DatabaseConnection) SaveLastMessageID(messages []domain.Message) error {
-	if len(messages) == 0 {
-		return nil
-	}
-	ctx := context.Background()
-
 	maxPerChat := make(map[domain.ChatID]domain.MessageID, len(messages))
 	for _, m := range messages {
 		if m.ID == 0 || m.ChatID == 0 {
 			continue
 		}
-		if cur, ok := maxPerChat[m.ChatID]; !ok || m.ID > cur {
-			maxPerChat[m.ChatID] = m.ID
+		if cur, ok := maxPerChat[m.ChatID]; !ok || m.LastID() > cur {
+			maxPerChat[m.ChatID] = m.LastID()
 		}
 	}
+	return c.saveOffsets(maxPerChat)
+}
+
+// SaveLastEventID moves offsets past service events, which share message ids with the chat.
+func (c *DatabaseConnection) SaveLastEventID(events []domain.ServiceEvent) error {
+	maxPerChat := make(map[domain.ChatID]domain.MessageID, len(events))
+	for _, e := range events {
+		if e.ID == 0 || e.ChatID == 0 {
+			continue
+		}
+		if cur, ok := maxPerChat[e.ChatID]; !ok || e.ID > cur {
+			maxPerChat[e.ChatID] = e.ID
+		}
+	}
+	return c.saveOffsets(maxPerChat)
+}
+
+func (c *DatabaseConnection) saveOffsets(maxPerChat map[domain.ChatID]domain.MessageID) error {
 	if len(maxPerChat) == 0 {
 		return nil
 	}
+	ctx := context.Background()
 
 	const q = `
 		INSERT INTO last_message_offsets (chat_id, last_message_id)
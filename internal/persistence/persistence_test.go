@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"testing"
 	"tg-bridge/internal/domain"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
@@ -113,6 +114,15 @@ func Test_SaveAndGetLastMessageID(t *testing.T) {
 				29: 120,
 			},
 		},
+		{
+			// album offset is its last merged message
+			msgs: []domain.Message{
+				{ID: 130, ChatID: 29, AlbumIDs: []domain.MessageID{130, 131, 132}},
+			},
+			checks: map[domain.ChatID]domain.MessageID{
+				29: 132,
+			},
+		},
 	}
 
 	for i, st := range steps {
@@ -159,3 +169,379 @@ func Test_SaveAndGetLastMessageID(t *testing.T) {
 
 	_ = ctx // reserved for potential future context usage
 }
+
+func Test_SaveAndGetMessageVersions(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	edited := func(unix int64) *time.Time {
+		ts := time.Unix(unix, 0).UTC()
+		return &ts
+	}
+
+	if err := db.SaveMessageVersions([]domain.Message{
+		{ID: 1, ChatID: 1, Text: "original"},
+		{ID: 2, ChatID: 1, Text: "edited", EditDate: edited(200)},
+		{ID: 1, ChatID: 2, Text: "other chat"},
+	}); err != nil {
+		t.Fatalf("SaveMessageVersions failed: %v", err)
+	}
+
+	// older edit should not overwrite a newer one
+	if err := db.SaveMessageVersions([]domain.Message{
+		{ID: 1, ChatID: 1, Text: "first edit", EditDate: edited(100)},
+		{ID: 2, ChatID: 1, Text: "stale", EditDate: edited(150)},
+	}); err != nil {
+		t.Fatalf("SaveMessageVersions failed: %v", err)
+	}
+
+	got, err := db.GetMessageVersions(1, []domain.MessageID{1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetMessageVersions failed: %v", err)
+	}
+
+	want := map[domain.MessageID]MessageVersion{
+		1: {Text: "first edit", EditDate: *edited(100)},
+		2: {Text: "edited", EditDate: *edited(200)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetMessageVersions returned %d versions, want %d: %v", len(got), len(want), got)
+	}
+	for id, w := range want {
+		if g := got[id]; g.Text != w.Text || !g.EditDate.Equal(w.EditDate) {
+			t.Fatalf("version of message %d = %+v, want %+v", id, g, w)
+		}
+	}
+
+	// every part of an album is recorded with the version of the album
+	if err := db.SaveMessageVersions([]domain.Message{
+		{ID: 10, ChatID: 1, Text: "album", AlbumIDs: []domain.MessageID{10, 11, 12}},
+	}); err != nil {
+		t.Fatalf("SaveMessageVersions failed: %v", err)
+	}
+	parts, err := db.GetMessageVersions(1, []domain.MessageID{11})
+	if err != nil {
+		t.Fatalf("GetMessageVersions failed: %v", err)
+	}
+	if g := parts[11]; g.Text != "album" || g.AlbumID != 10 {
+		t.Fatalf("version of album part 11 = %+v, want text album of album 10", g)
+	}
+	partIDs, err := db.GetAlbumPartIDs(1, 10)
+	if err != nil {
+		t.Fatalf("GetAlbumPartIDs failed: %v", err)
+	}
+	if fmt.Sprint(partIDs) != fmt.Sprint([]domain.MessageID{10, 11, 12}) {
+		t.Fatalf("GetAlbumPartIDs = %v, want [10 11 12]", partIDs)
+	}
+}
+
+func Test_MessageDeletions(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.SaveMessageVersions([]domain.Message{
+		{ID: 1, ChatID: 1, Text: "first"},
+		{ID: 2, ChatID: 1, Text: "second"},
+		{ID: 3, ChatID: 1, Text: "third"},
+		{ID: 4, ChatID: 2, Text: "other chat"},
+	}); err != nil {
+		t.Fatalf("SaveMessageVersions failed: %v", err)
+	}
+
+	deletion := domain.MessageDeletion{ID: 2, ChatID: 1, DetectedAt: time.Now().UTC()}
+	// repeated deletion is ignored
+	for i := 0; i < 2; i++ {
+		if err := db.SaveMessageDeletions([]domain.MessageDeletion{deletion}); err != nil {
+			t.Fatalf("SaveMessageDeletions failed: %v", err)
+		}
+	}
+
+	recent, err := db.GetRecentMessageIDs(1, 10)
+	if err != nil {
+		t.Fatalf("GetRecentMessageIDs failed: %v", err)
+	}
+	if fmt.Sprint(recent) != fmt.Sprint([]domain.MessageID{3, 1}) {
+		t.Fatalf("GetRecentMessageIDs = %v, want [3 1]", recent)
+	}
+
+	limited, err := db.GetRecentMessageIDs(1, 1)
+	if err != nil {
+		t.Fatalf("GetRecentMessageIDs failed: %v", err)
+	}
+	if fmt.Sprint(limited) != fmt.Sprint([]domain.MessageID{3}) {
+		t.Fatalf("GetRecentMessageIDs with limit = %v, want [3]", limited)
+	}
+
+	live, err := db.GetLiveMessageIDs(1, []domain.MessageID{1, 2, 4, 5})
+	if err != nil {
+		t.Fatalf("GetLiveMessageIDs failed: %v", err)
+	}
+	if fmt.Sprint(live) != fmt.Sprint([]domain.MessageID{1}) {
+		t.Fatalf("GetLiveMessageIDs = %v, want [1]", live)
+	}
+}
+
+func Test_ResolvedPeers(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	if _, ok, err := db.GetResolvedPeer("@citygroup"); err != nil || ok {
+		t.Fatalf("GetResolvedPeer of unknown ref = %v, %v, want not found", ok, err)
+	}
+
+	peer := domain.Peer{
+		ChatID:     1234567890,
+		AccessHash: -8765432109876543210,
+		Kind:       domain.PeerChannel,
+		Forum:      true,
+		Username:   "citygroup",
+		Title:      "City",
+		ResolvedAt: time.Unix(1700000000, 0).UTC(),
+	}
+	if err := db.SaveResolvedPeer("@citygroup", peer); err != nil {
+		t.Fatalf("SaveResolvedPeer failed: %v", err)
+	}
+	// re-resolved peer replaces the cached one
+	peer.AccessHash = 42
+	peer.ResolvedAt = peer.ResolvedAt.Add(time.Hour)
+	if err := db.SaveResolvedPeer("@citygroup", peer); err != nil {
+		t.Fatalf("SaveResolvedPeer failed: %v", err)
+	}
+
+	got, ok, err := db.GetResolvedPeer("@citygroup")
+	if err != nil || !ok {
+		t.Fatalf("GetResolvedPeer = %v, %v, want found", ok, err)
+	}
+	got.ResolvedAt = got.ResolvedAt.UTC()
+	if got != peer {
+		t.Fatalf("GetResolvedPeer = %+v, want %+v", got, peer)
+	}
+
+	if err := db.DeleteResolvedPeer("@citygroup"); err != nil {
+		t.Fatalf("DeleteResolvedPeer failed: %v", err)
+	}
+	if _, ok, err := db.GetResolvedPeer("@citygroup"); err != nil || ok {
+		t.Fatalf("GetResolvedPeer after delete = %v, %v, want not found", ok, err)
+	}
+}
+
+func Test_SaveLastEventID(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.SaveLastMessageID([]domain.Message{{ID: 10, ChatID: 1}}); err != nil {
+		t.Fatalf("SaveLastMessageID failed: %v", err)
+	}
+	// events move offset forward only
+	if err := db.SaveLastEventID([]domain.ServiceEvent{{ID: 12, ChatID: 1}, {ID: 11, ChatID: 1}, {ID: 5, ChatID: 2}}); err != nil {
+		t.Fatalf("SaveLastEventID failed: %v", err)
+	}
+	if err := db.SaveLastEventID([]domain.ServiceEvent{{ID: 3, ChatID: 2}}); err != nil {
+		t.Fatalf("SaveLastEventID failed: %v", err)
+	}
+
+	for chat, want := range map[domain.ChatID]domain.MessageID{1: 12, 2: 5} {
+		got, err := db.GetLastMessageID(chat)
+		if err != nil {
+			t.Fatalf("GetLastMessageID failed: %v", err)
+		}
+		if got != want {
+			t.Fatalf("offset of chat %d = %d, want %d", chat, got, want)
+		}
+	}
+}
+
+func Test_PinnedMessages(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	steps := []struct {
+		save   domain.MessageID
+		want   domain.MessageID
+		wantOk bool
+	}{
+		{save: 0, want: 0},
+		{save: 15, want: 15, wantOk: true},
+		{save: 12, want: 12, wantOk: true},
+		// unpinned
+		{save: 0, want: 0, wantOk: true},
+	}
+	for i, step := range steps {
+		if i > 0 {
+			change := domain.PinnedChange{ChatID: 1, MessageID: step.save, DetectedAt: time.Now().UTC()}
+			if err := db.SavePinnedMessageID(change); err != nil {
+				t.Fatalf("SavePinnedMessageID failed: %v", err)
+			}
+		}
+		got, ok, err := db.GetPinnedMessageID(1)
+		if err != nil {
+			t.Fatalf("GetPinnedMessageID failed: %v", err)
+		}
+		if got != step.want || ok != step.wantOk {
+			t.Fatalf("step %d: pinned message = %d (recorded %v), want %d (recorded %v)", i, got, ok, step.want, step.wantOk)
+		}
+	}
+}
+
+func Test_Engagement(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(10 * time.Minute)
+	snapshots := []domain.Engagement{
+		{ChatID: 1, MessageID: 5, Views: 10, Forwards: 1, Reactions: 2, CapturedAt: first},
+		{ChatID: 1, MessageID: 6, Views: 3, CapturedAt: first},
+		{ChatID: 1, MessageID: 5, Views: 25, Forwards: 1, Reactions: 4, CapturedAt: second},
+	}
+	if err := db.SaveEngagement(snapshots); err != nil {
+		t.Fatalf("SaveEngagement failed: %v", err)
+	}
+	// the same snapshot saved again is kept once
+	if err := db.SaveEngagement(snapshots[:1]); err != nil {
+		t.Fatalf("SaveEngagement failed: %v", err)
+	}
+
+	got, err := db.GetEngagement(1, 5)
+	if err != nil {
+		t.Fatalf("GetEngagement failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("snapshots = %d, want 2", len(got))
+	}
+	if got[0].Views != 10 || got[1].Views != 25 || got[1].Reactions != 4 {
+		t.Fatalf("unexpected snapshots: %+v", got)
+	}
+	if !got[1].CapturedAt.Equal(second) {
+		t.Fatalf("captured at = %v, want %v", got[1].CapturedAt, second)
+	}
+}
+
+func Test_CommentOffsets(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.SaveCommentOffset(1, 10, 500); err != nil {
+		t.Fatalf("SaveCommentOffset failed: %v", err)
+	}
+	if err := db.SaveCommentOffset(1, 11, 520); err != nil {
+		t.Fatalf("SaveCommentOffset failed: %v", err)
+	}
+	// offset never moves backwards
+	if err := db.SaveCommentOffset(1, 10, 490); err != nil {
+		t.Fatalf("SaveCommentOffset failed: %v", err)
+	}
+	// the same post id of another channel has its own offset
+	if err := db.SaveCommentOffset(2, 10, 7); err != nil {
+		t.Fatalf("SaveCommentOffset failed: %v", err)
+	}
+
+	got, err := db.GetCommentOffsets(1, []domain.MessageID{10, 11, 12})
+	if err != nil {
+		t.Fatalf("GetCommentOffsets failed: %v", err)
+	}
+	want := map[domain.MessageID]domain.MessageID{10: 500, 11: 520}
+	if len(got) != len(want) || got[10] != want[10] || got[11] != want[11] {
+		t.Fatalf("offsets = %v, want %v", got, want)
+	}
+}
+
+func Test_BackfillCheckpoints(t *testing.T) {
+	container, connStr := startPostgres(t)
+	defer func() {
+		_ = container.Terminate(context.Background())
+	}()
+
+	db, err := NewDatabase(connStr)
+	if err != nil {
+		t.Fatalf("failed to connect db: %v", err)
+	}
+	defer db.Close()
+
+	_, ok, err := db.GetBackfillCheckpoint(1, "ids:0-0,dates:-")
+	if err != nil || ok {
+		t.Fatalf("GetBackfillCheckpoint of a new range = %v, %v", ok, err)
+	}
+
+	updated := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []domain.BackfillCheckpoint{
+		{ChatID: 1, Range: "ids:0-0,dates:-", Cursor: 500, UpdatedAt: updated},
+		{ChatID: 1, Range: "ids:0-0,dates:-", Cursor: 400, UpdatedAt: updated.Add(time.Minute)},
+		{ChatID: 1, Range: "ids:0-0,dates:-", Done: true, UpdatedAt: updated.Add(2 * time.Minute)},
+	}
+	for i, cp := range steps {
+		if err := db.SaveBackfillCheckpoint(cp); err != nil {
+			t.Fatalf("SaveBackfillCheckpoint failed: %v", err)
+		}
+		got, ok, err := db.GetBackfillCheckpoint(cp.ChatID, cp.Range)
+		if err != nil || !ok {
+			t.Fatalf("GetBackfillCheckpoint failed: %v, %v", ok, err)
+		}
+		if got.Cursor != cp.Cursor || got.Done != cp.Done || !got.UpdatedAt.Equal(cp.UpdatedAt) {
+			t.Fatalf("step %d: checkpoint = %+v, want %+v", i, got, cp)
+		}
+	}
+
+	if err := db.DeleteBackfillCheckpoint(1, "ids:0-0,dates:-"); err != nil {
+		t.Fatalf("DeleteBackfillCheckpoint failed: %v", err)
+	}
+	if _, ok, _ := db.GetBackfillCheckpoint(1, "ids:0-0,dates:-"); ok {
+		t.Fatalf("checkpoint is not deleted")
+	}
+}
@@ -0,0 +1,38 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SavePinnedMessageID records the message currently pinned in the chat, zero when nothing is pinned.
+func (c *DatabaseConnection) SavePinnedMessageID(change domain.PinnedChange) error {
+	ctx := context.Background()
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO pinned_messages (chat_id, message_id, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id)
+		DO UPDATE SET message_id = EXCLUDED.message_id, updated_at = EXCLUDED.updated_at
+	`, int64(change.ChatID), int64(change.MessageID), change.DetectedAt)
+	return err
+}
+
+// GetPinnedMessageID returns the message last seen pinned in the chat, ok is false if none was recorded.
+func (c *DatabaseConnection) GetPinnedMessageID(chat domain.ChatID) (domain.MessageID, bool, error) {
+	ctx := context.Background()
+	var id int64
+	err := c.pool.QueryRow(ctx, `
+		SELECT message_id
+		FROM pinned_messages
+		WHERE chat_id = $1
+	`, int64(chat)).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return domain.MessageID(id), true, nil
+}
@@ -0,0 +1,122 @@
+package persistence
+
+import (
+	"context"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// MessageVersion is the last known version of a published message.
+type MessageVersion struct {
+	// EditDate is zero if message was never edited
+	EditDate time.Time
+	Text     string
+	// AlbumID is the id of the message the album was published as, zero outside of albums
+	AlbumID domain.MessageID
+}
+
+// SaveMessageVersions records text and edit date of messages, older versions never overwrite newer ones.
+// Each part of an album is recorded with the version of the album, so its edits and deletions are found.
+func (c *DatabaseConnection) SaveMessageVersions(messages []domain.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	const q = `
+		INSERT INTO message_versions (chat_id, message_id, edit_date, text, album_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id, message_id)
+		DO UPDATE SET edit_date = EXCLUDED.edit_date, text = EXCLUDED.text, album_id = EXCLUDED.album_id
+		WHERE EXCLUDED.edit_date >= message_versions.edit_date
+	`
+
+	batch := &pgx.Batch{}
+	for _, m := range messages {
+		if len(m.AlbumIDs) == 0 {
+			batch.Queue(q, int64(m.ChatID), int64(m.ID), editDateOf(m), m.Text, int64(0))
+			continue
+		}
+		for _, id := range m.AlbumIDs {
+			batch.Queue(q, int64(m.ChatID), int64(id), editDateOf(m), m.Text, int64(m.ID))
+		}
+	}
+
+	br := c.pool.SendBatch(ctx, batch)
+	defer func(br pgx.BatchResults) {
+		_ = br.Close()
+	}(br)
+
+	for range batch.Len() {
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMessageVersions returns known versions of the chat messages by id, unknown messages are absent.
+func (c *DatabaseConnection) GetMessageVersions(chat domain.ChatID, ids []domain.MessageID) (map[domain.MessageID]MessageVersion, error) {
+	result := make(map[domain.MessageID]MessageVersion, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	ctx := context.Background()
+
+	rawIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		rawIDs = append(rawIDs, int64(id))
+	}
+
+	rows, err := c.pool.Query(ctx, `
+		SELECT message_id, edit_date, text, album_id
+		FROM message_versions
+		WHERE chat_id = $1 AND message_id = ANY($2)
+	`, int64(chat), rawIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id       int64
+			editDate int64
+			text     string
+			albumID  int64
+		)
+		if err := rows.Scan(&id, &editDate, &text, &albumID); err != nil {
+			return nil, err
+		}
+		v := MessageVersion{Text: text, AlbumID: domain.MessageID(albumID)}
+		if editDate != 0 {
+			v.EditDate = time.Unix(editDate, 0).UTC()
+		}
+		result[domain.MessageID(id)] = v
+	}
+	return result, rows.Err()
+}
+
+// GetAlbumPartIDs returns ids of the recorded parts of the album published as albumID, in ascending order.
+func (c *DatabaseConnection) GetAlbumPartIDs(chat domain.ChatID, albumID domain.MessageID) ([]domain.MessageID, error) {
+	ctx := context.Background()
+	rows, err := c.pool.Query(ctx, `
+		SELECT message_id
+		FROM message_versions
+		WHERE chat_id = $1 AND album_id = $2
+		ORDER BY message_id
+	`, int64(chat), int64(albumID))
+	if err != nil {
+		return nil, err
+	}
+	return scanMessageIDs(rows)
+}
+
+func editDateOf(m domain.Message) int64 {
+	if m.EditDate == nil {
+		return 0
+	}
+	return m.EditDate.Unix()
+}
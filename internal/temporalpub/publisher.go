@@ -2,12 +2,15 @@ package temporalpub
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"tg-bridge/internal/config"
 	"tg-bridge/internal/domain"
 	"time"
 
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/client"
 )
 
@@ -53,24 +56,95 @@ func (p *Publisher) Close() error {
 }
 
 func (p *Publisher) StartTelegramWorkflow(ctx context.Context, msg domain.Message) (workflowID, runID string, err error) {
-	if p.tc == nil {
-		return "", "", fmt.Errorf("temporal client is not initialized")
-	}
+	return p.start(ctx, p.workflowIDFor(msg), p.cfg.TemporalWorkflowType, msg)
+}
+
+// StartTelegramBackfillWorkflow starts the message workflow for a backfilled message. The workflow id is
+// the same as for live messages and is never reused, so messages published before are skipped.
+func (p *Publisher) StartTelegramBackfillWorkflow(ctx context.Context, msg domain.Message) (workflowID string, started bool, err error) {
 	wfID := p.workflowIDFor(msg)
+	_, err = p.startWith(ctx, client.StartWorkflowOptions{
+		ID:                    wfID,
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+		// otherwise a still running workflow of the id is returned as if it was started now
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}, p.cfg.TemporalWorkflowType, msg)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return wfID, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return wfID, true, nil
+}
 
-	opts := client.StartWorkflowOptions{
-		ID:                       wfID,
-		TaskQueue:                p.cfg.TemporalTaskQueue,
-		WorkflowExecutionTimeout: 24 * time.Hour,
+// StartTelegramEditWorkflow starts a workflow per message edit, identified by the edit date.
+func (p *Publisher) StartTelegramEditWorkflow(ctx context.Context, edit domain.MessageEdit) (workflowID, runID string, err error) {
+	if p.cfg.TemporalEditWorkflowType == "" {
+		return "", "", fmt.Errorf("edit workflow type is not configured")
 	}
+	return p.start(ctx, p.editWorkflowIDFor(edit), p.cfg.TemporalEditWorkflowType, edit)
+}
+
+// StartTelegramDeleteWorkflow starts a workflow per deleted message.
+func (p *Publisher) StartTelegramDeleteWorkflow(ctx context.Context, deletion domain.MessageDeletion) (workflowID, runID string, err error) {
+	if p.cfg.TemporalDeleteWorkflowType == "" {
+		return "", "", fmt.Errorf("delete workflow type is not configured")
+	}
+	wfID := fmt.Sprintf("tg:%d:%d:delete", deletion.ChatID, deletion.ID)
+	return p.start(ctx, wfID, p.cfg.TemporalDeleteWorkflowType, deletion)
+}
+
+// StartTelegramEventWorkflow starts a workflow per service event, such as pinning a message.
+func (p *Publisher) StartTelegramEventWorkflow(ctx context.Context, event domain.ServiceEvent) (workflowID, runID string, err error) {
+	if p.cfg.TemporalEventWorkflowType == "" {
+		return "", "", fmt.Errorf("event workflow type is not configured")
+	}
+	wfID := fmt.Sprintf("tg:%d:%d:event", event.ChatID, event.ID)
+	return p.start(ctx, wfID, p.cfg.TemporalEventWorkflowType, event)
+}
 
-	run, err := p.tc.ExecuteWorkflow(ctx, opts, p.cfg.TemporalWorkflowType, msg)
+// StartTelegramPinnedWorkflow starts a workflow per change of the pinned message of a chat.
+func (p *Publisher) StartTelegramPinnedWorkflow(ctx context.Context, change domain.PinnedChange) (workflowID, runID string, err error) {
+	if p.cfg.TemporalPinnedWorkflowType == "" {
+		return "", "", fmt.Errorf("pinned workflow type is not configured")
+	}
+	wfID := fmt.Sprintf("tg:%d:pinned:%d:%d", change.ChatID, change.MessageID, change.DetectedAt.Unix())
+	return p.start(ctx, wfID, p.cfg.TemporalPinnedWorkflowType, change)
+}
+
+func (p *Publisher) start(ctx context.Context, wfID string, workflowType string, arg any) (workflowID, runID string, err error) {
+	run, err := p.startWith(ctx, client.StartWorkflowOptions{ID: wfID}, workflowType, arg)
 	if err != nil {
-		return "", "", fmt.Errorf("execute workflow: %w", err)
+		return "", "", err
 	}
 	return run.GetID(), run.GetRunID(), nil
 }
 
+func (p *Publisher) startWith(ctx context.Context, opts client.StartWorkflowOptions, workflowType string, arg any) (client.WorkflowRun, error) {
+	if p.tc == nil {
+		return nil, fmt.Errorf("temporal client is not initialized")
+	}
+
+	opts.TaskQueue = p.cfg.TemporalTaskQueue
+	opts.WorkflowExecutionTimeout = 24 * time.Hour
+
+	run, err := p.tc.ExecuteWorkflow(ctx, opts, workflowType, arg)
+	if err != nil {
+		return nil, fmt.Errorf("execute workflow: %w", err)
+	}
+	return run, nil
+}
+
 func (p *Publisher) workflowIDFor(msg domain.Message) string {
 	return fmt.Sprintf("tg:%d:%d", msg.ChatID, msg.ID)
 }
+
+func (p *Publisher) editWorkflowIDFor(edit domain.MessageEdit) string {
+	var editDate int64
+	if edit.Message.EditDate != nil {
+		editDate = edit.Message.EditDate.Unix()
+	}
+	return fmt.Sprintf("%s:edit:%d", p.workflowIDFor(edit.Message), editDate)
+}
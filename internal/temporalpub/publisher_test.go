@@ -16,8 +16,12 @@ import (
 	"github.com/testcontainers/testcontainers-go/network"
 	"github.com/testcontainers/testcontainers-go/wait"
 
+	"github.com/stretchr/testify/mock"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 )
@@ -310,3 +314,67 @@ func dumpContainerLogs(t *testing.T, ctx context.Context, name string, c testcon
 		t.Logf("[%s] container logs:\n%s", name, string(b))
 	}
 }
+
+func TestPublisher_StartTelegramBackfillWorkflow(t *testing.T) {
+	tc := mocks.NewClient(t)
+	cfg := config.Config{TemporalTaskQueue: "tg-bridge", TemporalWorkflowType: "TelegramMessage"}
+	pub, err := NewPublisher(cfg, nil, tc)
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+
+	backfilled := mock.MatchedBy(func(opts client.StartWorkflowOptions) bool {
+		return opts.ID == "tg:100:7" &&
+			opts.TaskQueue == "tg-bridge" &&
+			opts.WorkflowIDReusePolicy == enumspb.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE &&
+			opts.WorkflowExecutionErrorWhenAlreadyStarted
+	})
+	run := &mocks.WorkflowRun{}
+	tc.On("ExecuteWorkflow", mock.Anything, backfilled, "TelegramMessage", mock.Anything).Return(run, nil).Once()
+	tc.On("ExecuteWorkflow", mock.Anything, backfilled, "TelegramMessage", mock.Anything).
+		Return(nil, serviceerror.NewWorkflowExecutionAlreadyStarted("already started", "", "run")).Once()
+
+	msg := domain.Message{ID: 7, ChatID: 100}
+	wfID, started, err := pub.StartTelegramBackfillWorkflow(context.Background(), msg)
+	if err != nil || !started || wfID != "tg:100:7" {
+		t.Fatalf("first start = %q, %v, %v", wfID, started, err)
+	}
+
+	// the message was published already, by the bridge or a previous backfill
+	wfID, started, err = pub.StartTelegramBackfillWorkflow(context.Background(), msg)
+	if err != nil || started || wfID != "tg:100:7" {
+		t.Fatalf("second start = %q, %v, %v", wfID, started, err)
+	}
+}
+
+func TestPublisher_StartTelegramBackfillWorkflow_WhileRunning(t *testing.T) {
+	tc := mocks.NewClient(t)
+	cfg := config.Config{TemporalTaskQueue: "tg-bridge", TemporalWorkflowType: "TelegramMessage"}
+	pub, err := NewPublisher(cfg, nil, tc)
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+
+	// as Temporal: a start of a running workflow id returns its run unless asked to fail
+	running := make(map[string]client.WorkflowRun)
+	tc.On("ExecuteWorkflow", mock.Anything, mock.Anything, "TelegramMessage", mock.Anything).
+		Return(func(_ context.Context, opts client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+			if run, ok := running[opts.ID]; ok {
+				if opts.WorkflowExecutionErrorWhenAlreadyStarted {
+					return nil, serviceerror.NewWorkflowExecutionAlreadyStarted("already started", "", "run")
+				}
+				return run, nil
+			}
+			running[opts.ID] = &mocks.WorkflowRun{}
+			return running[opts.ID], nil
+		})
+
+	msg := domain.Message{ID: 7, ChatID: 100}
+	if _, started, err := pub.StartTelegramBackfillWorkflow(context.Background(), msg); err != nil || !started {
+		t.Fatalf("first start = %v, %v", started, err)
+	}
+	// resumed backfill publishes the page again while the first workflow still runs
+	if _, started, err := pub.StartTelegramBackfillWorkflow(context.Background(), msg); err != nil || started {
+		t.Fatalf("start while running = %v, %v, want skipped", started, err)
+	}
+}
@@ -0,0 +1,75 @@
+package tgclient
+
+import (
+	"tg-bridge/internal/domain"
+
+	"github.com/gotd/td/tg"
+)
+
+// maxAlbumSize is the max number of messages grouped into an album.
+const maxAlbumSize = 10
+
+// mergeAlbums merges consecutive messages of one album into a single message.
+// Messages must be in ascending id order.
+func mergeAlbums(msgs []domain.Message) []domain.Message {
+	result := make([]domain.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if len(result) > 0 {
+			last := &result[len(result)-1]
+			if m.GroupedID != 0 && last.GroupedID == m.GroupedID {
+				mergeInto(last, m)
+				continue
+			}
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// mergeInto adds a part of the album to it. Telegram keeps album caption in one of the parts,
+// usually the first one.
+func mergeInto(album *domain.Message, part domain.Message) {
+	album.AlbumIDs = append(album.AlbumIDs, part.ID)
+	album.Attachments = append(album.Attachments, part.Attachments...)
+	if album.Text == "" && part.Text != "" {
+		album.Text = part.Text
+		album.Entities = part.Entities
+	}
+	if part.EditDate != nil && (album.EditDate == nil || part.EditDate.After(*album.EditDate)) {
+		album.EditDate = part.EditDate
+	}
+	// parts are viewed and forwarded together, but reacted to one by one
+	album.Views = max(album.Views, part.Views)
+	album.Forwards = max(album.Forwards, part.Forwards)
+	album.Reactions += part.Reactions
+}
+
+// albumOf returns the album id of a raw message, zero when it is not a part of an album.
+func albumOf(obj tg.MessageClass) int64 {
+	msg, ok := obj.(*tg.Message)
+	if !ok {
+		return 0
+	}
+	groupedID, _ := msg.GetGroupedID()
+	return groupedID
+}
+
+// withoutTrailingAlbum drops parts of the album history in ascending id order ends with, as the rest
+// of its parts may be newer than the fetched range. History of a single album can't be split and is kept.
+func withoutTrailingAlbum(history []tg.MessageClass) []tg.MessageClass {
+	if len(history) == 0 {
+		return history
+	}
+	groupedID := albumOf(history[len(history)-1])
+	if groupedID == 0 {
+		return history
+	}
+	end := len(history)
+	for end > 0 && albumOf(history[end-1]) == groupedID {
+		end--
+	}
+	if end == 0 {
+		return history
+	}
+	return history[:end]
+}
@@ -0,0 +1,47 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeAlbums(t *testing.T) {
+	edited := time.Unix(1700000600, 0).UTC()
+	photo := func(id int64) []domain.Attachment {
+		return []domain.Attachment{{Kind: domain.AttachmentPhoto, File: &domain.FileReference{ID: id}}}
+	}
+
+	msgs := []domain.Message{
+		{ID: 10, Text: "single"},
+		{ID: 11, GroupedID: 1, AlbumIDs: []domain.MessageID{11}, Attachments: photo(11),
+			Views: 100, Forwards: 2, Reactions: 3},
+		{ID: 12, GroupedID: 1, AlbumIDs: []domain.MessageID{12}, Attachments: photo(12), Text: "caption",
+			Entities: []domain.Entity{{Kind: domain.EntityBold, Length: 7}}, EditDate: &edited,
+			Views: 98, Forwards: 2, Reactions: 1},
+		{ID: 13, GroupedID: 2, AlbumIDs: []domain.MessageID{13}, Attachments: photo(13)},
+		{ID: 14, Text: "after"},
+	}
+
+	got := mergeAlbums(msgs)
+
+	assert.Len(t, got, 4)
+	assert.Equal(t, "single", got[0].Text)
+
+	album := got[1]
+	assert.Equal(t, domain.MessageID(11), album.ID)
+	assert.Equal(t, []domain.MessageID{11, 12}, album.AlbumIDs)
+	assert.Equal(t, domain.MessageID(12), album.LastID())
+	assert.Equal(t, "caption", album.Text)
+	assert.Len(t, album.Entities, 1)
+	assert.Equal(t, append(photo(11), photo(12)...), album.Attachments)
+	assert.Equal(t, &edited, album.EditDate)
+	assert.Equal(t, 100, album.Views)
+	assert.Equal(t, 2, album.Forwards)
+	assert.Equal(t, 4, album.Reactions)
+
+	assert.Equal(t, []domain.MessageID{13}, got[2].AlbumIDs)
+	assert.Equal(t, domain.MessageID(14), got[3].ID)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/dcs"
 	"github.com/gotd/td/tg"
 )
 
@@ -22,12 +23,35 @@ type AuthParams struct {
 	TelegramApiHash string
 	Phone           string
 	Password        string
+	// Proxy is the URL of the proxy to connect through, see NewResolver
+	Proxy string
+	// TestDC generates the session on test DCs
+	TestDC bool
 }
 
-func CreateTelegramClient(apiId int, apiHash string, sessionStorage session.Storage) *telegram.Client {
-	return telegram.NewClient(apiId, apiHash, telegram.Options{
+// ClientOptions are optional settings of the Telegram client.
+type ClientOptions struct {
+	// UpdateHandler receives updates pushed by Telegram, updates are ignored if nil.
+	UpdateHandler telegram.UpdateHandler
+	// Middlewares wrap every API call, the first one is the outermost.
+	Middlewares []telegram.Middleware
+	// Resolver connects to Telegram data centers, directly if nil.
+	Resolver dcs.Resolver
+	// TestDC connects to test DCs instead of production ones, sessions of the two don't mix.
+	TestDC bool
+}
+
+func CreateTelegramClient(apiId int, apiHash string, sessionStorage session.Storage, opts ClientOptions) *telegram.Client {
+	options := telegram.Options{
 		SessionStorage: sessionStorage,
-	})
+		UpdateHandler:  opts.UpdateHandler,
+		Middlewares:    opts.Middlewares,
+		Resolver:       opts.Resolver,
+	}
+	if opts.TestDC {
+		options.DCList = dcs.Test()
+	}
+	return telegram.NewClient(apiId, apiHash, options)
 }
 
 func CheckTelegramSession(ctx context.Context, client *telegram.Client, onNotAuthorized func() error) error {
@@ -49,16 +73,27 @@ func AuthWithPhoneNumber(params AuthParams) error {
 	ctx := context.Background()
 	storage := tgsession.NewMemorySessionStorage([]byte{})
 	if params.DryRun {
-		return GenerateSampleSession(ctx, storage)
+		return GenerateSampleSession(ctx, storage, params.TestDC)
 	}
 
-	client := CreateTelegramClient(params.TelegramApiId, params.TelegramApiHash, storage)
-	err := client.Run(ctx, func(ctx context.Context) error {
+	resolver, err := NewResolver(params.Proxy)
+	if err != nil {
+		return err
+	}
+	client := CreateTelegramClient(params.TelegramApiId, params.TelegramApiHash, storage, ClientOptions{
+		Resolver: resolver,
+		TestDC:   params.TestDC,
+	})
+	err = client.Run(ctx, func(ctx context.Context) error {
 		err := initiateAuthCodeRequest(ctx, params, client)
 		if err != nil {
 			return err
 		}
 		sessionBase64, errSession := GetEncodedSession(ctx, storage)
+		if errSession == nil {
+			// Telegram reports test mode in the config stored with the session
+			errSession = tgsession.CheckDCMode(sessionBase64, params.TestDC)
+		}
 		color.Cyan("Base64 encoded session")
 		color.Green(sessionBase64)
 		if errSession != nil {
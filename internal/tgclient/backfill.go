@@ -0,0 +1,91 @@
+package tgclient
+
+import (
+	"context"
+	"slices"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// BackfillPage returns a page of messages of the range older than cursor in ascending id order and
+// the cursor of the next page, zero when the start of the range is reached. History is walked from
+// the newest message of the range, zero cursor starts the walk.
+func (c *Channel) BackfillPage(ctx context.Context, r domain.HistoryRange, cursor domain.MessageID, limit int) ([]domain.Message, domain.MessageID, error) {
+	offsetID, offsetDate := int(cursor), 0
+	if cursor == 0 {
+		switch {
+		case r.ToID != 0:
+			offsetID = int(r.ToID) + 1
+		case !r.To.IsZero():
+			offsetDate = int(r.To.Unix()) + 1
+		}
+	}
+	minID := 0
+	if r.FromID != 0 {
+		minID = int(r.FromID) - 1
+	}
+
+	users := make(map[int64]*tg.User)
+	history, err := c.threadPage(ctx, c.topicID, limit, offsetID, 0, offsetDate, minID, users)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page, next := backfillPage(history, r, len(history) == limit)
+	slices.Reverse(page)
+	msgs, _, err := c.toMessages(page, users)
+	return msgs, next, err
+}
+
+// backfillPage keeps messages of the range from history page ordered newest first and returns
+// the next cursor. An album at the end of a full page is left for the next one, as its older parts
+// may be there.
+func backfillPage(history []tg.MessageClass, r domain.HistoryRange, full bool) ([]tg.MessageClass, domain.MessageID) {
+	var (
+		page       []tg.MessageClass
+		reachedEnd = !full
+	)
+	for _, obj := range history {
+		date, ok := dateOf(obj)
+		if !ok {
+			continue
+		}
+		if !r.From.IsZero() && date.Before(r.From) {
+			reachedEnd = true
+			break
+		}
+		if r.Contains(domain.MessageID(obj.GetID()), date) {
+			page = append(page, obj)
+		}
+	}
+	if len(history) == 0 || reachedEnd {
+		return page, 0
+	}
+
+	oldest := history[len(history)-1]
+	if msg, ok := oldest.(*tg.Message); ok {
+		if groupedID, ok := msg.GetGroupedID(); ok {
+			trimmed := slices.DeleteFunc(slices.Clone(page), func(obj tg.MessageClass) bool {
+				m, ok := obj.(*tg.Message)
+				return ok && m.GroupedID == groupedID
+			})
+			// a page of a single album can't be split
+			if len(trimmed) > 0 {
+				return trimmed, domain.MessageID(trimmed[len(trimmed)-1].GetID())
+			}
+		}
+	}
+	return page, domain.MessageID(oldest.GetID())
+}
+
+func dateOf(obj tg.MessageClass) (time.Time, bool) {
+	switch msg := obj.(type) {
+	case *tg.Message:
+		return time.Unix(int64(msg.Date), 0).UTC(), true
+	case *tg.MessageService:
+		return time.Unix(int64(msg.Date), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,63 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfillPage(t *testing.T) {
+	day := func(d int) int { return int(time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC).Unix()) }
+	history := []tg.MessageClass{
+		&tg.Message{ID: 40, Date: day(20)},
+		&tg.Message{ID: 39, Date: day(15)},
+		&tg.MessageService{ID: 38, Date: day(14), Action: &tg.MessageActionPinMessage{}},
+		&tg.Message{ID: 37, Date: day(10)},
+	}
+
+	t.Run("full page continues before the oldest message", func(t *testing.T) {
+		page, next := backfillPage(history, domain.HistoryRange{}, true)
+		assert.Equal(t, []int{40, 39, 38, 37}, ids(page))
+		assert.Equal(t, domain.MessageID(37), next)
+	})
+
+	t.Run("short page ends the walk", func(t *testing.T) {
+		page, next := backfillPage(history, domain.HistoryRange{}, false)
+		assert.Len(t, page, 4)
+		assert.Zero(t, next)
+	})
+
+	t.Run("messages before the from date end the walk", func(t *testing.T) {
+		r := domain.HistoryRange{From: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)}
+		page, next := backfillPage(history, r, true)
+		assert.Equal(t, []int{40, 39, 38}, ids(page))
+		assert.Zero(t, next)
+	})
+
+	t.Run("messages after the to date are skipped", func(t *testing.T) {
+		r := domain.HistoryRange{To: time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)}
+		page, next := backfillPage(history, r, true)
+		assert.Equal(t, []int{39, 38, 37}, ids(page))
+		assert.Equal(t, domain.MessageID(37), next)
+	})
+
+	t.Run("album at the page boundary is left for the next page", func(t *testing.T) {
+		part := func(id int) *tg.Message {
+			m := &tg.Message{ID: id, Date: day(5)}
+			m.SetGroupedID(7)
+			return m
+		}
+		withAlbum := []tg.MessageClass{&tg.Message{ID: 50, Date: day(6)}, part(49), part(48)}
+		page, next := backfillPage(withAlbum, domain.HistoryRange{}, true)
+		assert.Equal(t, []int{50}, ids(page))
+		assert.Equal(t, domain.MessageID(50), next)
+
+		// a page of a single album can't be split
+		page, next = backfillPage(withAlbum[1:], domain.HistoryRange{}, true)
+		assert.Equal(t, []int{49, 48}, ids(page))
+		assert.Equal(t, domain.MessageID(48), next)
+	})
+}
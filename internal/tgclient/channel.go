@@ -3,117 +3,484 @@ package tgclient
 import (
 	"context"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"tg-bridge/internal/domain"
 	"time"
 
-	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/tg"
 )
 
+// Channel is a source of messages: a broadcast channel, a supergroup or a basic group.
+// Messages of a forum supergroup may be limited to a single topic.
 type Channel struct {
-	client   *telegram.Client
+	api  *tg.Client
+	id   int64
+	peer tg.InputPeerClass
+	// channel is nil for basic groups
 	channel  *tg.Channel
+	topicID  int
 	supplier domain.Supplier
+	// discussion is the linked group comments of posts are in, only when comments are followed
+	discussion *Channel
+	// renamedFrom is the previous username when the channel was renamed since it was last resolved
+	renamedFrom string
 }
 
-func NewChannel(ctx context.Context, client *telegram.Client, name string, supplier domain.Supplier) (*Channel, error) {
+// NewChannel resolves channel by username, numeric id, t.me/c/<id> reference or invite link.
+// Calls are made with invoker, usually a *telegram.Client. Resolved chats are kept in cache, which may be nil.
+func NewChannel(ctx context.Context, invoker tg.Invoker, name string, supplier domain.Supplier, cache PeerCache) (*Channel, error) {
+	ref, err := ParseChannelRef(name)
+	if err != nil {
+		return nil, err
+	}
 
-	api := client.API()
+	api := tg.NewClient(invoker)
+	chat, oldUsername, err := resolveCached(ctx, api, ref, cache)
+	if err != nil {
+		return nil, err
+	}
 
-	resolved, err := api.ContactsResolveUsername(ctx,
-		&tg.ContactsResolveUsernameRequest{
-			Username: name,
-		})
+	c, err := newChannel(api, chat, ref.TopicID, supplier)
 	if err != nil {
-		err := fmt.Errorf("failed to resolve channel username: %v", err)
 		return nil, err
 	}
+	c.renamedFrom = oldUsername
+	return c, nil
+}
+
+func newChannel(api *tg.Client, chat tg.ChatClass, topicID int, supplier domain.Supplier) (*Channel, error) {
+	c := &Channel{
+		api:      api,
+		topicID:  topicID,
+		supplier: supplier,
+	}
+	switch chat := chat.(type) {
+	case *tg.Channel:
+		if topicID != 0 && !chat.Forum {
+			return nil, fmt.Errorf("topic %d requested, but %d is not a forum", topicID, chat.ID)
+		}
+		c.id = chat.ID
+		c.peer = chat.AsInputPeer()
+		c.channel = chat
+	case *tg.Chat:
+		if topicID != 0 {
+			return nil, fmt.Errorf("topic %d requested, but %d is a basic group", topicID, chat.ID)
+		}
+		c.id = chat.ID
+		c.peer = &tg.InputPeerChat{ChatID: chat.ID}
+	default:
+		return nil, fmt.Errorf("unsupported chat type: %T", chat)
+	}
+	return c, nil
+}
+
+// Messages returns messages and service events posted after offset in ascending id order.
+// Pages are requested forwards from the offset until the newest message is reached or maxPages
+// are fetched, then the oldest messages are returned and the next call continues after them.
+// Without offset only the latest page is returned.
+func (c *Channel) Messages(ctx context.Context, limit int, offset int, maxPages int) ([]domain.Message, []domain.ServiceEvent, error) {
+	users := make(map[int64]*tg.User)
+	if offset == 0 {
+		history, err := c.historyPage(ctx, limit, 0, 0, 0, users)
+		if err != nil {
+			return nil, nil, err
+		}
+		slices.Reverse(history)
+		// the page may start in the middle of an album when there are older messages
+		if len(history) == limit {
+			if history, err = c.withLeadingAlbum(ctx, history, users); err != nil {
+				return nil, nil, err
+			}
+		}
+		return c.toMessages(history, users)
+	}
 
-	if len(resolved.Chats) == 1 {
+	history, truncated, err := pageForward(func(offsetID, addOffset int) ([]tg.MessageClass, error) {
+		return c.historyPage(ctx, limit, offsetID, addOffset, offset, users)
+	}, limit, offset, maxPages)
+	if err != nil {
+		return nil, nil, err
+	}
+	if truncated {
+		// the next call continues from the album, so it is not split
+		history = withoutTrailingAlbum(history)
+		log.Printf("history page cap (%d) reached for channel %d, messages after %d are fetched on the next poll",
+			maxPages, c.id, history[len(history)-1].GetID())
+	}
 
-		channel, converted := resolved.Chats[0].(*tg.Channel)
+	return c.toMessages(history, users)
+}
 
-		if !converted {
-			return nil, fmt.Errorf("is not a channel: %v", name)
+// withLeadingAlbum adds older parts of the album history in ascending id order starts with.
+func (c *Channel) withLeadingAlbum(ctx context.Context, history []tg.MessageClass, users map[int64]*tg.User) ([]tg.MessageClass, error) {
+	groupedID := albumOf(history[0])
+	if groupedID == 0 {
+		return history, nil
+	}
+	older, err := c.historyPage(ctx, maxAlbumSize-1, history[0].GetID(), 0, 0, users)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range older {
+		if albumOf(obj) != groupedID {
+			break
 		}
+		history = slices.Insert(history, 0, obj)
+	}
+	return history, nil
+}
 
-		return &Channel{
-			client:   client,
-			channel:  channel,
-			supplier: supplier,
-		}, nil
+// Recent returns the latest limit messages in ascending id order regardless of offset.
+func (c *Channel) Recent(ctx context.Context, limit int) ([]domain.Message, error) {
+	users := make(map[int64]*tg.User)
+	history, err := c.historyPage(ctx, limit, 0, 0, 0, users)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("channels found: %v", len(resolved.Chats))
+	slices.Reverse(history)
+	msgs, _, err := c.toMessages(history, users)
+	return msgs, err
 }
 
-func (c *Channel) Messages(ctx context.Context, limit int, offset int) ([]domain.Message, error) {
-	hist, err := c.client.API().MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
-		Peer:  c.channel.AsInputPeer(),
-		Limit: limit,
-		MinID: offset,
-	})
+// Missing returns ids of the given messages which no longer exist in the channel.
+func (c *Channel) Missing(ctx context.Context, ids []domain.MessageID) ([]domain.MessageID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	msgs, _, err := c.messagesByID(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
 
-	channelMsgs, ok := hist.(*tg.MessagesChannelMessages)
-	if !ok {
-		return nil, fmt.Errorf("unexpected history type: %T", hist)
+	var missing []domain.MessageID
+	for _, obj := range msgs {
+		if empty, ok := obj.(*tg.MessageEmpty); ok {
+			missing = append(missing, domain.MessageID(empty.ID))
+		}
+	}
+	return missing, nil
+}
+
+// Album fetches the parts of an album by their ids and merges them, false when all of them were deleted.
+func (c *Channel) Album(ctx context.Context, ids []domain.MessageID) (domain.Message, bool, error) {
+	if len(ids) == 0 {
+		return domain.Message{}, false, nil
+	}
+
+	fetched, fetchedUsers, err := c.messagesByID(ctx, ids)
+	if err != nil {
+		return domain.Message{}, false, err
+	}
+	users := make(map[int64]*tg.User, len(fetchedUsers))
+	for _, obj := range fetchedUsers {
+		if user, ok := obj.(*tg.User); ok {
+			users[user.ID] = user
+		}
+	}
+	// parts are merged in ascending id order
+	slices.SortFunc(fetched, func(a, b tg.MessageClass) int { return a.GetID() - b.GetID() })
+
+	msgs, _, err := c.toMessages(fetched, users)
+	if err != nil || len(msgs) == 0 {
+		return domain.Message{}, false, err
+	}
+	return msgs[0], true, nil
+}
+
+// messagesByID fetches messages of the chat by their ids, deleted ones are returned as MessageEmpty.
+func (c *Channel) messagesByID(ctx context.Context, ids []domain.MessageID) ([]tg.MessageClass, []tg.UserClass, error) {
+	request := make([]tg.InputMessageClass, 0, len(ids))
+	for _, id := range ids {
+		request = append(request, &tg.InputMessageID{ID: int(id)})
+	}
+
+	var (
+		res tg.MessagesMessagesClass
+		err error
+	)
+	if c.channel != nil {
+		res, err = c.api.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: c.channel.AsInput(),
+			ID:      request,
+		})
+	} else {
+		// message ids of basic groups are shared by all private chats of the account
+		res, err = c.api.MessagesGetMessages(ctx, request)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return messagesOf(res)
+}
+
+// PinnedID returns the latest pinned message of the chat, zero when nothing is pinned.
+func (c *Channel) PinnedID(ctx context.Context) (domain.MessageID, error) {
+	var (
+		full *tg.MessagesChatFull
+		err  error
+	)
+	if c.channel != nil {
+		full, err = c.api.ChannelsGetFullChannel(ctx, c.channel.AsInput())
+	} else {
+		full, err = c.api.MessagesGetFullChat(ctx, c.id)
+	}
+	if err != nil {
+		return 0, err
 	}
+	pinned, _ := full.FullChat.GetPinnedMsgID()
+	return domain.MessageID(pinned), nil
+}
 
-	result := make([]domain.Message, 0, len(channelMsgs.Messages))
+// historyPage returns up to limit messages older than offsetID and newer than minID, newest first.
+// Negative addOffset shifts the page to newer messages, -limit returns the oldest ones from offsetID on.
+// Authors of the messages are added to users.
+func (c *Channel) historyPage(ctx context.Context, limit int, offsetID int, addOffset int, minID int, users map[int64]*tg.User) ([]tg.MessageClass, error) {
+	// a forum topic is a thread of replies to its first message
+	return c.threadPage(ctx, c.topicID, limit, offsetID, addOffset, 0, minID, users)
+}
+
+// threadPage is historyPage of replies to threadID, which are comments for posts of a broadcast channel.
+// Zero threadID pages the whole history. Without offsetID the page starts before offsetDate if it is set.
+func (c *Channel) threadPage(ctx context.Context, threadID int, limit int, offsetID int, addOffset int, offsetDate int, minID int, users map[int64]*tg.User) ([]tg.MessageClass, error) {
+	var (
+		hist tg.MessagesMessagesClass
+		err  error
+	)
+	if threadID != 0 {
+		hist, err = c.api.MessagesGetReplies(ctx, &tg.MessagesGetRepliesRequest{
+			Peer:       c.peer,
+			MsgID:      threadID,
+			Limit:      limit,
+			OffsetID:   offsetID,
+			AddOffset:  addOffset,
+			OffsetDate: offsetDate,
+			MinID:      minID,
+		})
+	} else {
+		hist, err = c.api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:       c.peer,
+			Limit:      limit,
+			OffsetID:   offsetID,
+			AddOffset:  addOffset,
+			OffsetDate: offsetDate,
+			MinID:      minID,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	for _, obj := range channelMsgs.Messages {
-		msg, ok := obj.(*tg.Message)
-		if !ok {
-			continue
+	msgs, pageUsers, err := messagesOf(hist)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range pageUsers {
+		if user, ok := obj.(*tg.User); ok {
+			users[user.ID] = user
 		}
+	}
+	return msgs, nil
+}
+
+// messagesOf unpacks any of messages responses: channels return MessagesChannelMessages,
+// groups MessagesMessages or MessagesMessagesSlice when not all of the messages fit.
+func messagesOf(res tg.MessagesMessagesClass) ([]tg.MessageClass, []tg.UserClass, error) {
+	modified, ok := res.AsModified()
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected messages type: %T", res)
+	}
+	return modified.GetMessages(), modified.GetUsers(), nil
+}
 
-		var fromUserID int64
-		if pu, ok := msg.FromID.(*tg.PeerUser); ok {
-			fromUserID = pu.UserID
+// pageForward collects history newer than minID in ascending id order. fetch returns a page of up to limit
+// messages newest first, with addOffset -limit the oldest ones from offsetID on, so pages are requested
+// from minID towards the newest message. Fewer than limit new messages in a page mean the newest one is reached,
+// otherwise the result is truncated by maxPages and holds the oldest messages. maxPages <= 0 means no cap.
+func pageForward(fetch func(offsetID, addOffset int) ([]tg.MessageClass, error), limit int, minID int, maxPages int) ([]tg.MessageClass, bool, error) {
+	var result []tg.MessageClass
+	next := minID + 1
+	for page := 0; maxPages <= 0 || page < maxPages; page++ {
+		msgs, err := fetch(next, -limit)
+		if err != nil {
+			return nil, false, err
+		}
+		newer := 0
+		for _, m := range slices.Backward(msgs) {
+			if m.GetID() >= next {
+				next = m.GetID() + 1
+				result = append(result, m)
+				newer++
+			}
 		}
+		// when fewer messages are newer, the page is filled with older ones
+		if newer < limit {
+			return result, false, nil
+		}
+	}
+	return result, true, nil
+}
+
+// toMessages converts history to domain messages and service events.
+// Parts of an album are merged into one message.
+func (c *Channel) toMessages(history []tg.MessageClass, users map[int64]*tg.User) ([]domain.Message, []domain.ServiceEvent, error) {
+	result := make([]domain.Message, 0, len(history))
+	var events []domain.ServiceEvent
 
-		var reply *domain.MessageRef
-		if msg.ReplyTo != nil {
-			if msgReply, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok {
-				reply = &domain.MessageRef{
-					ID:     domain.MessageID(msgReply.ReplyToMsgID),
-					ChatID: domain.ChatID(c.channel.ID),
-				}
+	for _, obj := range history {
+		switch msg := obj.(type) {
+		case *tg.Message:
+			newMess, err := c.toMessage(msg, users)
+			if err != nil {
+				return nil, nil, err
 			}
+
+			result = append(
+				result,
+				newMess,
+			)
+		case *tg.MessageService:
+			events = append(events, c.toEvent(msg, users))
 		}
+	}
+
+	return mergeAlbums(result), events, nil
+}
 
-		ctxMap := map[string]any{
-			"supplier": c.supplier.Type,
+// toMessage converts a raw Telegram message of the channel into domain.Message.
+// users are the known authors, messages of channels are signed by PostAuthor instead.
+func (c *Channel) toMessage(msg *tg.Message, users map[int64]*tg.User) (domain.Message, error) {
+	from := userOf(msg.FromID, users)
+	if msg.PostAuthor != "" {
+		from.Name = msg.PostAuthor
+	}
+
+	var reply *domain.MessageRef
+	if msg.ReplyTo != nil {
+		if msgReply, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok && msgReply.ReplyToMsgID != topicOf(msg.ReplyTo) {
+			reply = &domain.MessageRef{
+				ID:     domain.MessageID(msgReply.ReplyToMsgID),
+				ChatID: domain.ChatID(c.id),
+			}
+			// replies to messages of other chats carry their peer
+			if peer, ok := msgReply.GetReplyToPeerID(); ok {
+				reply.ChatID = chatIDOf(peer)
+			}
 		}
+	}
 
-		newMess, err := domain.NewMessage(
-			domain.MessageID(msg.ID),
-			domain.ChatID(c.channel.ID),
-			domain.User{
-				ID:   domain.UserID(fromUserID),
-				Name: msg.PostAuthor,
-			},
-			msg.Message,
-			time.Unix(int64(msg.Date), 0).UTC(),
-			reply,
-			ctxMap,
-		)
+	ctxMap := map[string]any{
+		"supplier": c.supplier.Type,
+	}
 
-		if err != nil {
-			return nil, err
+	newMess, err := domain.NewMessage(
+		domain.MessageID(msg.ID),
+		domain.ChatID(c.id),
+		from,
+		msg.Message,
+		time.Unix(int64(msg.Date), 0).UTC(),
+		reply,
+		ctxMap,
+	)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	newMess.TopicID = topicOf(msg.ReplyTo)
+	if fwd, ok := msg.GetFwdFrom(); ok {
+		newMess.ForwardedFrom = forwardOf(fwd)
+	}
+	newMess.Entities = entitiesOf(msg.Message, msg.Entities)
+
+	if groupedID, ok := msg.GetGroupedID(); ok {
+		newMess.GroupedID = groupedID
+		newMess.AlbumIDs = []domain.MessageID{newMess.ID}
+	}
+
+	if media, ok := msg.GetMedia(); ok {
+		newMess.Attachments = attachmentsOf(media)
+		placesOf(&newMess, media)
+	}
+
+	newMess.Views, _ = msg.GetViews()
+	newMess.Forwards, _ = msg.GetForwards()
+	if reactions, ok := msg.GetReactions(); ok {
+		for _, r := range reactions.Results {
+			newMess.Reactions += r.Count
 		}
+	}
+
+	if editDate, ok := msg.GetEditDate(); ok {
+		edited := time.Unix(int64(editDate), 0).UTC()
+		newMess.EditDate = &edited
+	}
+
+	return newMess, nil
+}
+
+// accepts reports whether a message or service message with the reply header belongs to the configured topic, if any.
+func (c *Channel) accepts(replyTo tg.MessageReplyHeaderClass) bool {
+	return c.topicID == 0 || topicOf(replyTo) == c.topicID
+}
+
+// topicOf returns forum topic id of a message by its reply header, zero outside of forums and in the General topic.
+// A message posted to a topic replies to its first message, unless it is a reply to another message of the topic.
+func topicOf(replyTo tg.MessageReplyHeaderClass) int {
+	header, ok := replyTo.(*tg.MessageReplyHeader)
+	if !ok || !header.ForumTopic {
+		return 0
+	}
+	if topID, ok := header.GetReplyToTopID(); ok {
+		return topID
+	}
+	return header.ReplyToMsgID
+}
 
-		result = append(
-			result,
-			newMess,
-		)
+// userOf returns the author of a message, only users are known by id.
+func userOf(from tg.PeerClass, users map[int64]*tg.User) domain.User {
+	pu, ok := from.(*tg.PeerUser)
+	if !ok {
+		return domain.User{}
 	}
+	result := domain.User{ID: domain.UserID(pu.UserID)}
+	if user, ok := users[pu.UserID]; ok {
+		result.Name = userName(user)
+	}
+	return result
+}
 
-	return result, nil
+func userName(user *tg.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		return user.Username
+	}
+	return name
 }
 
 func (c *Channel) Id() int64 {
-	return c.channel.ID
+	return c.id
+}
+
+// API returns the client of the account the channel was resolved with.
+func (c *Channel) API() *tg.Client {
+	return c.api
+}
+
+// Username returns the current username of the channel, empty for private channels and groups.
+func (c *Channel) Username() string {
+	if c.channel == nil {
+		return ""
+	}
+	return c.channel.Username
+}
+
+// RenamedFrom returns the previous username if the channel was renamed since it was last resolved.
+func (c *Channel) RenamedFrom() (string, bool) {
+	return c.renamedFrom, c.renamedFrom != ""
+}
+
+func (c *Channel) Supplier() domain.Supplier {
+	return c.supplier
 }
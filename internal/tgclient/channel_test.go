@@ -0,0 +1,137 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHistory serves history of messages with ids (minID, lastID] newest first, like MessagesGetHistory.
+// Negative addOffset is supported as -limit only, serving the oldest messages from offsetID on.
+func fakeHistory(t *testing.T, lastID, minID, limit int, calls *int) func(offsetID, addOffset int) ([]tg.MessageClass, error) {
+	t.Helper()
+	return func(offsetID, addOffset int) ([]tg.MessageClass, error) {
+		*calls++
+		start := lastID
+		if offsetID != 0 {
+			start = offsetID - 1
+		}
+		if addOffset == -limit {
+			start = min(max(offsetID, minID+1)+limit-1, lastID)
+		}
+		var page []tg.MessageClass
+		for id := start; id > minID && len(page) < limit; id-- {
+			page = append(page, &tg.Message{ID: id})
+		}
+		return page, nil
+	}
+}
+
+func ids(msgs []tg.MessageClass) []int {
+	result := make([]int, 0, len(msgs))
+	for _, m := range msgs {
+		result = append(result, m.GetID())
+	}
+	return result
+}
+
+func TestPageForward(t *testing.T) {
+	tests := []struct {
+		name      string
+		lastID    int
+		minID     int
+		limit     int
+		maxPages  int
+		wantFirst int
+		wantLast  int
+		wantCalls int
+		truncated bool
+	}{
+		{name: "single_page", lastID: 15, minID: 10, limit: 25, maxPages: 10, wantFirst: 11, wantLast: 15, wantCalls: 1},
+		{name: "several_pages", lastID: 100, minID: 10, limit: 25, maxPages: 10, wantFirst: 11, wantLast: 100, wantCalls: 4},
+		{name: "exact_pages", lastID: 60, minID: 10, limit: 25, maxPages: 10, wantFirst: 11, wantLast: 60, wantCalls: 3},
+		{name: "no_cap", lastID: 1000, minID: 0, limit: 25, maxPages: 0, wantFirst: 1, wantLast: 1000, wantCalls: 41},
+		{name: "capped", lastID: 100, minID: 10, limit: 25, maxPages: 2, wantFirst: 11, wantLast: 60, wantCalls: 2, truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			got, truncated, err := pageForward(fakeHistory(t, tt.lastID, tt.minID, tt.limit, &calls), tt.limit, tt.minID, tt.maxPages)
+			require.NoError(t, err)
+			assert.Equal(t, tt.truncated, truncated)
+
+			gotIDs := ids(got)
+			require.Len(t, gotIDs, tt.wantLast-tt.wantFirst+1)
+			assert.Equal(t, tt.wantFirst, gotIDs[0])
+			assert.Equal(t, tt.wantLast, gotIDs[len(gotIDs)-1])
+			assert.IsIncreasing(t, gotIDs, "messages should be in ascending id order without gaps")
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestPageForward_Empty(t *testing.T) {
+	calls := 0
+	got, truncated, err := pageForward(fakeHistory(t, 10, 10, 25, &calls), 25, 10, 10)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Empty(t, got)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMessagesOf(t *testing.T) {
+	user := &tg.User{ID: 42}
+	responses := []tg.MessagesMessagesClass{
+		&tg.MessagesChannelMessages{Messages: []tg.MessageClass{&tg.Message{ID: 1}}, Users: []tg.UserClass{user}},
+		&tg.MessagesMessages{Messages: []tg.MessageClass{&tg.Message{ID: 1}}, Users: []tg.UserClass{user}},
+		&tg.MessagesMessagesSlice{Messages: []tg.MessageClass{&tg.Message{ID: 1}}, Users: []tg.UserClass{user}},
+	}
+	for _, res := range responses {
+		msgs, users, err := messagesOf(res)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, ids(msgs))
+		assert.Equal(t, []tg.UserClass{user}, users)
+	}
+
+	_, _, err := messagesOf(&tg.MessagesMessagesNotModified{})
+	assert.Error(t, err)
+}
+
+func TestNewChannel_RejectsTopicOutsideForum(t *testing.T) {
+	_, err := newChannel(nil, &tg.Channel{ID: 100}, 5, domain.Supplier{})
+	assert.Error(t, err)
+	_, err = newChannel(nil, &tg.Chat{ID: 300}, 5, domain.Supplier{})
+	assert.Error(t, err)
+
+	c, err := newChannel(nil, &tg.Chat{ID: 300}, 0, domain.Supplier{})
+	require.NoError(t, err)
+	assert.Equal(t, &tg.InputPeerChat{ChatID: 300}, c.peer)
+}
+
+func TestToMessage_Engagement(t *testing.T) {
+	c := testChannel(t, &tg.Channel{ID: 100, Broadcast: true}, 0, domain.Supplier{})
+
+	msg := &tg.Message{ID: 7, PeerID: &tg.PeerChannel{ChannelID: 100}, Date: 1700000000}
+	msg.SetViews(1500)
+	msg.SetForwards(12)
+	msg.SetReactions(tg.MessageReactions{Results: []tg.ReactionCount{
+		{Reaction: &tg.ReactionEmoji{Emoticon: "👍"}, Count: 30},
+		{Reaction: &tg.ReactionEmoji{Emoticon: "🔥"}, Count: 5},
+	}})
+
+	got, err := c.toMessage(msg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1500, got.Views)
+	assert.Equal(t, 12, got.Forwards)
+	assert.Equal(t, 35, got.Reactions)
+
+	snapshot := domain.EngagementOf(got, time.Unix(1700000600, 0).UTC())
+	assert.Equal(t, domain.ChatID(100), snapshot.ChatID)
+	assert.Equal(t, domain.MessageID(7), snapshot.MessageID)
+	assert.Equal(t, 35, snapshot.Reactions)
+}
@@ -0,0 +1,139 @@
+package tgclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"tg-bridge/internal/domain"
+
+	"github.com/gotd/td/tg"
+)
+
+// CommentedPost is a channel post with comments in the linked discussion group.
+type CommentedPost struct {
+	ID domain.MessageID
+	// LastCommentID is the id of the latest comment in the discussion group
+	LastCommentID domain.MessageID
+}
+
+// FollowComments finds the discussion group linked to the broadcast channel, comments of the posts
+// are fetched from it. The account doesn't have to join the group of a public channel.
+func (c *Channel) FollowComments(ctx context.Context) error {
+	if c.channel == nil || !c.channel.Broadcast {
+		return fmt.Errorf("chat %d is not a broadcast channel, it has no comments", c.id)
+	}
+
+	full, err := c.api.ChannelsGetFullChannel(ctx, c.channel.AsInput())
+	if err != nil {
+		return err
+	}
+	channelFull, ok := full.FullChat.(*tg.ChannelFull)
+	if !ok {
+		return fmt.Errorf("unexpected full chat type: %T", full.FullChat)
+	}
+	linkedID, ok := channelFull.GetLinkedChatID()
+	if !ok {
+		return fmt.Errorf("channel %d has no discussion group", c.id)
+	}
+
+	for _, chat := range full.Chats {
+		if chat.GetID() != linkedID {
+			continue
+		}
+		discussion, err := newChannel(c.api, chat, 0, c.supplier)
+		if err != nil {
+			return err
+		}
+		c.discussion = discussion
+		return nil
+	}
+	return fmt.Errorf("discussion group %d of channel %d is not accessible", linkedID, c.id)
+}
+
+// DiscussionID returns id of the linked discussion group when comments are followed.
+func (c *Channel) DiscussionID() (int64, bool) {
+	if c.discussion == nil {
+		return 0, false
+	}
+	return c.discussion.id, true
+}
+
+// CommentedPosts returns posts having comments among the latest limit messages in ascending id order.
+func (c *Channel) CommentedPosts(ctx context.Context, limit int) ([]CommentedPost, error) {
+	history, err := c.historyPage(ctx, limit, 0, 0, 0, make(map[int64]*tg.User))
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(history)
+	return commentedPosts(history), nil
+}
+
+func commentedPosts(history []tg.MessageClass) []CommentedPost {
+	var posts []CommentedPost
+	for _, obj := range history {
+		msg, ok := obj.(*tg.Message)
+		if !ok {
+			continue
+		}
+		replies, ok := msg.GetReplies()
+		if !ok || !replies.Comments {
+			continue
+		}
+		if maxID, ok := replies.GetMaxID(); ok {
+			posts = append(posts, CommentedPost{ID: domain.MessageID(msg.ID), LastCommentID: domain.MessageID(maxID)})
+		}
+	}
+	return posts
+}
+
+// Comments returns comments of the post newer than offset in ascending id order. Comments are messages
+// of the discussion group referencing the post in CommentOf, paged forwards from offset like Messages.
+func (c *Channel) Comments(ctx context.Context, post domain.MessageID, limit int, offset int, maxPages int) ([]domain.Message, error) {
+	if c.discussion == nil {
+		return nil, fmt.Errorf("comments of channel %d are not followed", c.id)
+	}
+
+	users := make(map[int64]*tg.User)
+	history, truncated, err := pageForward(func(offsetID, addOffset int) ([]tg.MessageClass, error) {
+		return c.threadPage(ctx, int(post), limit, offsetID, addOffset, 0, offset, users)
+	}, limit, offset, maxPages)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		history = withoutTrailingAlbum(history)
+		log.Printf("history page cap (%d) reached for comments of post %d of channel %d, comments after %d are fetched on the next poll",
+			maxPages, post, c.id, history[len(history)-1].GetID())
+	}
+
+	result := make([]domain.Message, 0, len(history))
+	for _, obj := range history {
+		msg, ok := obj.(*tg.Message)
+		if !ok {
+			continue
+		}
+		comment, err := c.toComment(msg, post, users)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, comment)
+	}
+	return mergeAlbums(result), nil
+}
+
+// toComment converts a message of the discussion group. Comments replying to the post itself reply
+// to its copy in the group, which consumers don't know, so only replies to other comments are kept.
+func (c *Channel) toComment(msg *tg.Message, post domain.MessageID, users map[int64]*tg.User) (domain.Message, error) {
+	comment, err := c.discussion.toMessage(msg, users)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	comment.CommentOf = &domain.MessageRef{ID: post, ChatID: domain.ChatID(c.id)}
+	if header, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok {
+		if _, ok := header.GetReplyToTopID(); !ok {
+			comment.ReplyTo = nil
+		}
+	}
+	return comment, nil
+}
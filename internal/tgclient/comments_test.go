@@ -0,0 +1,89 @@
+package tgclient
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgfake"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentedPosts(t *testing.T) {
+	replies := tg.MessageReplies{Comments: true, Replies: 3}
+	replies.SetMaxID(512)
+	commented := &tg.Message{ID: 10}
+	commented.SetReplies(replies)
+	noComments := &tg.Message{ID: 11}
+	noComments.SetReplies(tg.MessageReplies{Comments: true})
+	// replies of a group thread are not comments
+	threadReplies := tg.MessageReplies{Replies: 1}
+	threadReplies.SetMaxID(13)
+	thread := &tg.Message{ID: 12}
+	thread.SetReplies(threadReplies)
+
+	posts := commentedPosts([]tg.MessageClass{commented, noComments, thread, &tg.MessageService{ID: 14}})
+	assert.Equal(t, []CommentedPost{{ID: 10, LastCommentID: 512}}, posts)
+}
+
+func TestToComment(t *testing.T) {
+	c := testChannel(t, &tg.Channel{ID: 100, Broadcast: true}, 0, domain.Supplier{Type: "water"})
+	c.discussion = testChannel(t, &tg.Channel{ID: 200, Megagroup: true}, 0, domain.Supplier{Type: "water"})
+	users := map[int64]*tg.User{42: {ID: 42, FirstName: "Resident"}}
+
+	// comment to the post replies to its copy in the discussion group
+	toPost := &tg.Message{ID: 501, PeerID: &tg.PeerChannel{ChannelID: 200}, FromID: &tg.PeerUser{UserID: 42},
+		Message: "no water on Lenina st", Date: 1700000000}
+	postHeader := &tg.MessageReplyHeader{}
+	postHeader.SetReplyToMsgID(500)
+	toPost.SetReplyTo(postHeader)
+
+	got, err := c.toComment(toPost, 10, users)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ChatID(200), got.ChatID)
+	assert.Equal(t, "Resident", got.From.Name)
+	assert.Equal(t, &domain.MessageRef{ID: 10, ChatID: 100}, got.CommentOf)
+	assert.Nil(t, got.ReplyTo)
+
+	toComment := &tg.Message{ID: 502, PeerID: &tg.PeerChannel{ChannelID: 200}, Message: "same here", Date: 1700000060}
+	header := &tg.MessageReplyHeader{}
+	header.SetReplyToMsgID(501)
+	header.SetReplyToTopID(500)
+	toComment.SetReplyTo(header)
+
+	got, err = c.toComment(toComment, 10, users)
+	require.NoError(t, err)
+	assert.Equal(t, &domain.MessageRef{ID: 501, ChatID: 200}, got.ReplyTo)
+	assert.Equal(t, "water", got.Context["supplier"])
+}
+
+func TestComments_ResumeAfterPageCap(t *testing.T) {
+	ctx := context.Background()
+	api := tgfake.New()
+	api.Handle(&tg.MessagesGetRepliesRequest{}, func(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+		r := req.(*tg.MessagesGetRepliesRequest)
+		require.Equal(t, 10, r.MsgID)
+		require.Equal(t, -r.Limit, r.AddOffset, "comments should be paged forwards")
+		// comments 501..505, the oldest ones from offset id on, newest first
+		var page []tg.MessageClass
+		for id := max(r.OffsetID, r.MinID+1, 501); id <= 505 && len(page) < r.Limit; id++ {
+			page = slices.Insert(page, 0, tg.MessageClass(&tg.Message{ID: id, PeerID: &tg.PeerChannel{ChannelID: 200}, Date: 1700000000 + id}))
+		}
+		return &tg.MessagesMessages{Messages: page, Chats: []tg.ChatClass{}, Users: []tg.UserClass{}}, nil
+	})
+	c := testChannel(t, &tg.Channel{ID: 100, Broadcast: true}, 0, domain.Supplier{Type: "water"})
+	c.api = tg.NewClient(api)
+	c.discussion = testChannel(t, &tg.Channel{ID: 200, Megagroup: true}, 0, domain.Supplier{Type: "water"})
+
+	comments, err := c.Comments(ctx, 10, 2, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{501, 502}, messageIDs(comments), "oldest comments should be returned")
+
+	comments, err = c.Comments(ctx, 10, 2, 502, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{503, 504, 505}, messageIDs(comments), "next call should continue after them")
+}
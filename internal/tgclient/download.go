@@ -0,0 +1,117 @@
+package tgclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"tg-bridge/internal/blobstore"
+	"tg-bridge/internal/domain"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+)
+
+var errFileTooLarge = errors.New("file exceeds size limit")
+
+// MediaDownloader downloads message attachments into a blob store.
+type MediaDownloader struct {
+	store      blobstore.BlobStore
+	maxSize    int64
+	downloader *downloader.Downloader
+}
+
+func NewMediaDownloader(store blobstore.BlobStore, maxSize int64) *MediaDownloader {
+	return &MediaDownloader{
+		store:      store,
+		maxSize:    maxSize,
+		downloader: downloader.NewDownloader(),
+	}
+}
+
+// Attach downloads files of message attachments and sets their blob URIs. Files are downloaded by the
+// client the message was fetched with, file references are only valid for its account. Attachments which
+// exceed the size limit or fail to download are left without URI, so the message is still published.
+func (d *MediaDownloader) Attach(ctx context.Context, api *tg.Client, msg *domain.Message) {
+	for i := range msg.Attachments {
+		att := &msg.Attachments[i]
+		if att.File == nil || att.BlobURI != "" {
+			continue
+		}
+		if d.maxSize > 0 && att.Size > d.maxSize {
+			log.Printf("skip %s attachment of message %d: %d bytes exceed limit", att.Kind, msg.ID, att.Size)
+			continue
+		}
+		if err := d.download(ctx, api, att); err != nil {
+			log.Printf("download %s attachment of message %d error: %v", att.Kind, msg.ID, err)
+		}
+	}
+}
+
+func (d *MediaDownloader) download(ctx context.Context, api *tg.Client, att *domain.Attachment) error {
+	buf := &limitedBuffer{limit: d.maxSize}
+	if _, err := d.downloader.Download(api, fileLocation(att)).Stream(ctx, buf); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	att.SHA256 = hex.EncodeToString(sum[:])
+	key := blobKey(att)
+
+	// the same file is often posted to several channels or reposted, store it once
+	uri, found, err := d.store.Exists(ctx, key)
+	if err != nil {
+		return fmt.Errorf("check blob: %w", err)
+	}
+	if !found {
+		uri, err = d.store.Put(ctx, key, att.MimeType, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			return fmt.Errorf("store blob: %w", err)
+		}
+	}
+	att.BlobURI = uri
+	return nil
+}
+
+func fileLocation(att *domain.Attachment) tg.InputFileLocationClass {
+	if att.File.ThumbSize != "" {
+		return &tg.InputPhotoFileLocation{
+			ID:            att.File.ID,
+			AccessHash:    att.File.AccessHash,
+			FileReference: att.File.FileReference,
+			ThumbSize:     att.File.ThumbSize,
+		}
+	}
+	return &tg.InputDocumentFileLocation{
+		ID:            att.File.ID,
+		AccessHash:    att.File.AccessHash,
+		FileReference: att.File.FileReference,
+	}
+}
+
+// blobKey addresses the blob by content hash, keeping file extension for convenience.
+func blobKey(att *domain.Attachment) string {
+	ext := path.Ext(att.FileName)
+	if att.File.ThumbSize != "" {
+		// photos are always stored by Telegram as JPEG
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("sha256/%s/%s%s", att.SHA256[:2], att.SHA256, ext)
+}
+
+// limitedBuffer fails writes beyond limit, as attachment size reported by Telegram may be missing.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && int64(b.Len()+len(p)) > b.limit {
+		return 0, errFileTooLarge
+	}
+	return b.Buffer.Write(p)
+}
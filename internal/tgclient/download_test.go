@@ -0,0 +1,137 @@
+package tgclient
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"tg-bridge/internal/blobstore"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgfake"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts blobs put into the file store.
+type countingStore struct {
+	*blobstore.FileStore
+	puts int
+}
+
+func (s *countingStore) Put(ctx context.Context, key string, contentType string, r io.Reader, size int64) (string, error) {
+	s.puts++
+	return s.FileStore.Put(ctx, key, contentType, r, size)
+}
+
+// documentPost is a post with a document, size is the one reported by Telegram.
+func documentPost(id int, docID int64, name string, size int64) *tg.Message {
+	return &tg.Message{
+		ID:   id,
+		Date: 1700000000 + id,
+		Media: &tg.MessageMediaDocument{Document: &tg.Document{
+			ID:            docID,
+			AccessHash:    docID * 10,
+			FileReference: []byte{1},
+			MimeType:      "application/pdf",
+			Size:          size,
+			Attributes:    []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: name}},
+		}},
+	}
+}
+
+// serveFiles serves upload.getFile with contents of documents by their ids and counts requested ones.
+func serveFiles(api *tgfake.Invoker, files map[int64][]byte, requested map[int64]int) {
+	api.Handle(&tg.UploadGetFileRequest{}, func(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+		r := req.(*tg.UploadGetFileRequest)
+		loc := r.Location.(*tg.InputDocumentFileLocation)
+		requested[loc.ID]++
+		data := files[loc.ID]
+		start := min(int(r.Offset), len(data))
+		end := min(start+r.Limit, len(data))
+		return &tg.UploadFile{Type: &tg.StorageFilePdf{}, Bytes: data[start:end]}, nil
+	})
+}
+
+func TestMediaDownloader_AttachFromFakeAPI(t *testing.T) {
+	ctx := context.Background()
+	schedule := []byte("outage schedule for Main st")
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil,
+		documentPost(1, 10, "schedule.pdf", int64(len(schedule))),
+		// the same file reposted as another document
+		documentPost(2, 20, "schedule.pdf", int64(len(schedule))),
+		// reported size over the limit
+		documentPost(3, 30, "map.pdf", 1<<20),
+		// size not reported, the download itself exceeds the limit
+		documentPost(4, 40, "photos.pdf", 0),
+	)
+	requested := make(map[int64]int)
+	serveFiles(api, map[int64][]byte{10: schedule, 20: schedule, 30: make([]byte, 1<<20), 40: make([]byte, 1<<10)}, requested)
+
+	fileStore, err := blobstore.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	store := &countingStore{FileStore: fileStore}
+	media := NewMediaDownloader(store, 512)
+
+	ch, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+	msgs, _, err := ch.Messages(ctx, 10, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	for i := range msgs {
+		media.Attach(ctx, ch.API(), &msgs[i])
+	}
+
+	first := msgs[0].Attachments[0]
+	require.NotEmpty(t, first.BlobURI, "downloaded file should be attached to the message")
+	uri, err := url.Parse(first.BlobURI)
+	require.NoError(t, err)
+	stored, err := os.ReadFile(uri.Path)
+	require.NoError(t, err)
+	assert.Equal(t, schedule, stored)
+	assert.Equal(t, "file", uri.Scheme)
+	assert.True(t, strings.HasSuffix(uri.Path, "/sha256/"+first.SHA256[:2]+"/"+first.SHA256+".pdf"), "blob should be addressed by content hash")
+
+	assert.Equal(t, first.BlobURI, msgs[1].Attachments[0].BlobURI, "the same content should share the blob")
+	assert.Equal(t, 1, store.puts, "the same content should be stored once")
+
+	assert.Empty(t, msgs[2].Attachments[0].BlobURI)
+	assert.Zero(t, requested[30], "file over the reported size limit should not be downloaded")
+	assert.Empty(t, msgs[3].Attachments[0].BlobURI, "download over the limit should not be stored")
+
+	// attachments with URI are not downloaded again
+	media.Attach(ctx, ch.API(), &msgs[0])
+	assert.Equal(t, 1, requested[10])
+}
+
+func TestBlobKey(t *testing.T) {
+	const sum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	pdf := &domain.Attachment{FileName: "Schedule.PDF", SHA256: sum, File: &domain.FileReference{}}
+	assert.Equal(t, "sha256/9f/"+sum+".PDF", blobKey(pdf))
+
+	photo := &domain.Attachment{SHA256: sum, File: &domain.FileReference{ThumbSize: "y"}}
+	assert.Equal(t, "sha256/9f/"+sum+".jpg", blobKey(photo))
+
+	noName := &domain.Attachment{SHA256: sum, File: &domain.FileReference{}}
+	assert.Equal(t, "sha256/9f/"+sum, blobKey(noName))
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buf := &limitedBuffer{limit: 5}
+
+	_, err := buf.Write([]byte("abc"))
+	assert.NoError(t, err)
+	_, err = buf.Write([]byte("def"))
+	assert.ErrorIs(t, err, errFileTooLarge)
+	assert.Equal(t, "abc", buf.String())
+
+	unlimited := &limitedBuffer{}
+	_, err = unlimited.Write(make([]byte, 1<<20))
+	assert.NoError(t, err)
+}
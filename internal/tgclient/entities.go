@@ -0,0 +1,88 @@
+package tgclient
+
+import (
+	"tg-bridge/internal/domain"
+	"unicode/utf8"
+
+	"github.com/gotd/td/tg"
+)
+
+// entitiesOf converts message entities to domain ones. Telegram counts offsets in UTF-16 code units,
+// they are converted to byte offsets in text. Unknown entities are skipped.
+func entitiesOf(text string, raw []tg.MessageEntityClass) []domain.Entity {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	// byteOffsets[i] is the byte offset of i-th UTF-16 code unit, the last one is len(text)
+	byteOffsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		byteOffsets = append(byteOffsets, i)
+		if utf8.RuneLen(r) == 4 {
+			// surrogate pair, both halves point to the rune start
+			byteOffsets = append(byteOffsets, i)
+		}
+	}
+	byteOffsets = append(byteOffsets, len(text))
+
+	result := make([]domain.Entity, 0, len(raw))
+	for _, obj := range raw {
+		start, end := obj.GetOffset(), obj.GetOffset()+obj.GetLength()
+		if start < 0 || end > len(byteOffsets)-1 || start >= end {
+			continue
+		}
+
+		e := domain.Entity{
+			Offset: byteOffsets[start],
+			Length: byteOffsets[end] - byteOffsets[start],
+		}
+		switch v := obj.(type) {
+		case *tg.MessageEntityMention:
+			e.Kind = domain.EntityMention
+		case *tg.MessageEntityHashtag:
+			e.Kind = domain.EntityHashtag
+		case *tg.MessageEntityCashtag:
+			e.Kind = domain.EntityCashtag
+		case *tg.MessageEntityBotCommand:
+			e.Kind = domain.EntityBotCommand
+		case *tg.MessageEntityURL:
+			e.Kind = domain.EntityURL
+			e.URL = text[e.Offset : e.Offset+e.Length]
+		case *tg.MessageEntityEmail:
+			e.Kind = domain.EntityEmail
+		case *tg.MessageEntityPhone:
+			e.Kind = domain.EntityPhone
+		case *tg.MessageEntityBankCard:
+			e.Kind = domain.EntityBankCard
+		case *tg.MessageEntityTextURL:
+			e.Kind = domain.EntityTextURL
+			e.URL = v.URL
+		case *tg.MessageEntityMentionName:
+			e.Kind = domain.EntityMentionName
+			e.UserID = domain.UserID(v.UserID)
+		case *tg.MessageEntityBold:
+			e.Kind = domain.EntityBold
+		case *tg.MessageEntityItalic:
+			e.Kind = domain.EntityItalic
+		case *tg.MessageEntityUnderline:
+			e.Kind = domain.EntityUnderline
+		case *tg.MessageEntityStrike:
+			e.Kind = domain.EntityStrike
+		case *tg.MessageEntitySpoiler:
+			e.Kind = domain.EntitySpoiler
+		case *tg.MessageEntityCode:
+			e.Kind = domain.EntityCode
+		case *tg.MessageEntityPre:
+			e.Kind = domain.EntityPre
+			e.Language = v.Language
+		case *tg.MessageEntityBlockquote:
+			e.Kind = domain.EntityBlockquote
+		case *tg.MessageEntityCustomEmoji:
+			e.Kind = domain.EntityCustomEmoji
+		default:
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntitiesOf(t *testing.T) {
+	// "⚡" is one UTF-16 unit (3 bytes), "🔌" is a surrogate pair (4 bytes), Cyrillic letters take 2 bytes
+	text := "⚡🔌 Отключение #Бендеры подробнее"
+
+	got := entitiesOf(text, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 4, Length: 10},
+		&tg.MessageEntityHashtag{Offset: 15, Length: 8},
+		&tg.MessageEntityTextURL{Offset: 24, Length: 9, URL: "https://example.com/outage"},
+		&tg.MessageEntityItalic{Offset: 30, Length: 10}, // out of text
+		&tg.MessageEntityUnknown{Offset: 0, Length: 1},
+	})
+
+	want := []domain.Entity{
+		{Kind: domain.EntityBold, Offset: 8, Length: 20},
+		{Kind: domain.EntityHashtag, Offset: 29, Length: 15},
+		{Kind: domain.EntityTextURL, Offset: 45, Length: 18, URL: "https://example.com/outage"},
+	}
+	assert.Equal(t, want, got)
+
+	parts := make([]string, 0, len(got))
+	for _, e := range got {
+		parts = append(parts, text[e.Offset:e.Offset+e.Length])
+	}
+	assert.Equal(t, []string{"Отключение", "#Бендеры", "подробнее"}, parts)
+}
+
+func TestEntitiesOf_URL(t *testing.T) {
+	text := "see https://example.com"
+	got := entitiesOf(text, []tg.MessageEntityClass{&tg.MessageEntityURL{Offset: 4, Length: 19}})
+	assert.Equal(t, []domain.Entity{{Kind: domain.EntityURL, Offset: 4, Length: 19, URL: "https://example.com"}}, got)
+}
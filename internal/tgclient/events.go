@@ -0,0 +1,64 @@
+package tgclient
+
+import (
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// toEvent converts a service message of the channel into domain.ServiceEvent.
+func (c *Channel) toEvent(msg *tg.MessageService, users map[int64]*tg.User) domain.ServiceEvent {
+	event := domain.ServiceEvent{
+		ID:     domain.MessageID(msg.ID),
+		ChatID: domain.ChatID(c.id),
+		From:   userOf(msg.FromID, users),
+		Date:   time.Unix(int64(msg.Date), 0).UTC(),
+		Kind:   domain.EventOther,
+		Context: map[string]any{
+			"supplier": c.supplier.Type,
+		},
+		Action: msg.Action.TypeName(),
+	}
+
+	event.TopicID = topicOf(msg.ReplyTo)
+	header, _ := msg.ReplyTo.(*tg.MessageReplyHeader)
+
+	switch action := msg.Action.(type) {
+	case *tg.MessageActionPinMessage:
+		// pinned message is the one the service message replies to
+		event.Kind = domain.EventPinMessage
+		if header != nil {
+			event.PinnedID = domain.MessageID(header.ReplyToMsgID)
+		}
+	case *tg.MessageActionChatEditTitle:
+		event.Kind = domain.EventTitleChanged
+		event.Title = action.Title
+	case *tg.MessageActionChatEditPhoto:
+		event.Kind = domain.EventPhotoChanged
+		if photo, ok := action.Photo.(*tg.Photo); ok {
+			att := photoAttachment(photo)
+			event.Photo = &att
+		}
+	case *tg.MessageActionChatDeletePhoto:
+		event.Kind = domain.EventPhotoDeleted
+	case *tg.MessageActionChannelCreate:
+		event.Kind = domain.EventChannelCreated
+		event.Title = action.Title
+	case *tg.MessageActionChannelMigrateFrom:
+		event.Kind = domain.EventMigratedFrom
+		event.Title = action.Title
+		event.MigratedChatID = domain.ChatID(action.ChatID)
+	case *tg.MessageActionChatMigrateTo:
+		event.Kind = domain.EventMigratedTo
+		event.MigratedChatID = domain.ChatID(action.ChannelID)
+	case *tg.MessageActionTopicCreate:
+		event.Kind = domain.EventTopicCreated
+		event.Title = action.Title
+		event.TopicID = msg.ID
+	case *tg.MessageActionTopicEdit:
+		event.Kind = domain.EventTopicEdited
+		event.Title, _ = action.GetTitle()
+	}
+	return event
+}
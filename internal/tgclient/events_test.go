@@ -0,0 +1,100 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serviceMessage(id int, action tg.MessageActionClass) *tg.MessageService {
+	return &tg.MessageService{
+		ID:     id,
+		PeerID: &tg.PeerChannel{ChannelID: 100},
+		Date:   1700000000,
+		Action: action,
+	}
+}
+
+func TestToEvent(t *testing.T) {
+	c := testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{Type: "water"})
+
+	pin := serviceMessage(10, &tg.MessageActionPinMessage{})
+	pin.SetReplyTo(&tg.MessageReplyHeader{ReplyToMsgID: 7})
+	pin.SetFromID(&tg.PeerUser{UserID: 42})
+
+	tests := []struct {
+		name string
+		msg  *tg.MessageService
+		want domain.ServiceEvent
+	}{
+		{
+			name: "pin",
+			msg:  pin,
+			want: domain.ServiceEvent{Kind: domain.EventPinMessage, Action: "messageActionPinMessage", PinnedID: 7,
+				From: domain.User{ID: 42, Name: "Olga"}},
+		},
+		{
+			name: "title",
+			msg:  serviceMessage(10, &tg.MessageActionChatEditTitle{Title: "Vodokanal"}),
+			want: domain.ServiceEvent{Kind: domain.EventTitleChanged, Action: "messageActionChatEditTitle", Title: "Vodokanal"},
+		},
+		{
+			name: "migration",
+			msg:  serviceMessage(10, &tg.MessageActionChannelMigrateFrom{Title: "Old group", ChatID: 300}),
+			want: domain.ServiceEvent{Kind: domain.EventMigratedFrom, Action: "messageActionChannelMigrateFrom",
+				Title: "Old group", MigratedChatID: 300},
+		},
+		{
+			name: "topic",
+			msg:  serviceMessage(10, &tg.MessageActionTopicCreate{Title: "Outages"}),
+			want: domain.ServiceEvent{Kind: domain.EventTopicCreated, Action: "messageActionTopicCreate",
+				Title: "Outages", TopicID: 10},
+		},
+		{
+			name: "other",
+			msg:  serviceMessage(10, &tg.MessageActionHistoryClear{}),
+			want: domain.ServiceEvent{Kind: domain.EventOther, Action: "messageActionHistoryClear"},
+		},
+	}
+
+	users := map[int64]*tg.User{42: {ID: 42, FirstName: "Olga"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.toEvent(tt.msg, users)
+			assert.Equal(t, domain.MessageID(10), got.ID)
+			assert.Equal(t, domain.ChatID(100), got.ChatID)
+			assert.Equal(t, int64(1700000000), got.Date.Unix())
+			assert.Equal(t, "water", got.Context["supplier"])
+
+			got.ID, got.ChatID, got.Date, got.Context = 0, 0, tt.want.Date, nil
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestToEvent_PhotoChanged(t *testing.T) {
+	c := testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{})
+	photo := &tg.Photo{ID: 5, Sizes: []tg.PhotoSizeClass{&tg.PhotoSize{Type: "x", W: 640, H: 640, Size: 1000}}}
+
+	got := c.toEvent(serviceMessage(10, &tg.MessageActionChatEditPhoto{Photo: photo}), nil)
+	assert.Equal(t, domain.EventPhotoChanged, got.Kind)
+	require.NotNil(t, got.Photo)
+	assert.Equal(t, 640, got.Photo.Width)
+}
+
+func TestToMessages_SplitsServiceMessages(t *testing.T) {
+	c := testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{})
+
+	msgs, events, err := c.toMessages([]tg.MessageClass{
+		&tg.Message{ID: 1, PeerID: &tg.PeerChannel{ChannelID: 100}, Message: "outage", Date: 1700000000},
+		serviceMessage(2, &tg.MessageActionChatEditTitle{Title: "New"}),
+		&tg.MessageEmpty{ID: 3},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Len(t, events, 1)
+	assert.Equal(t, domain.MessageID(2), events[0].ID)
+}
@@ -0,0 +1,180 @@
+package tgclient
+
+import (
+	"context"
+	"testing"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgfake"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var waterChannel = &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+
+func waterPosts(ids ...int) []tg.MessageClass {
+	result := make([]tg.MessageClass, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, &tg.Message{ID: id, Message: "outage", Date: 1700000000 + id, PostAuthor: "Dispatcher"})
+	}
+	return result
+}
+
+func messageIDs(msgs []domain.Message) []domain.MessageID {
+	result := make([]domain.MessageID, 0, len(msgs))
+	for _, m := range msgs {
+		result = append(result, m.ID)
+	}
+	return result
+}
+
+func TestChannel_MessagesFromFakeAPI(t *testing.T) {
+	ctx := context.Background()
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil, waterPosts(1, 2, 3, 4, 5)...)
+
+	ch, err := NewChannel(ctx, api, "@WaterUtility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), ch.Id())
+
+	msgs, _, err := ch.Messages(ctx, 2, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{4, 5}, messageIDs(msgs), "new channel should start from the latest page")
+	assert.Equal(t, "Dispatcher", msgs[0].From.Name)
+	assert.Equal(t, "water", msgs[0].Context["supplier"])
+
+	api.Post(waterChannel.ID, waterPosts(6, 7, 8)...)
+	msgs, _, err = ch.Messages(ctx, 2, 5, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{6, 7, 8}, messageIDs(msgs), "pages should be fetched back to the offset")
+	assert.Equal(t, 3, api.Calls(&tg.MessagesGetHistoryRequest{}))
+}
+
+func TestChannel_MessagesResumeAfterPageCap(t *testing.T) {
+	ctx := context.Background()
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil, waterPosts(1, 2, 3, 4, 5, 6, 7)...)
+	ch, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+
+	msgs, _, err := ch.Messages(ctx, 2, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{2, 3, 4, 5}, messageIDs(msgs), "oldest messages after the offset should be returned")
+
+	msgs, _, err = ch.Messages(ctx, 2, 5, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{6, 7}, messageIDs(msgs), "next call should continue after them")
+}
+
+func TestChannel_MessagesKeepAlbumsAcrossPages(t *testing.T) {
+	ctx := context.Background()
+	history := waterPosts(1, 2, 3, 4, 5, 6, 7, 8)
+	for _, id := range []int{4, 5, 6} {
+		history[id-1].(*tg.Message).SetGroupedID(77)
+	}
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil, history...)
+	ch, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+
+	msgs, _, err := ch.Messages(ctx, 3, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{4, 7, 8}, messageIDs(msgs), "album starting the latest page should be completed")
+	assert.Equal(t, []domain.MessageID{4, 5, 6}, msgs[0].AlbumIDs)
+
+	msgs, _, err = ch.Messages(ctx, 2, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{3}, messageIDs(msgs), "album crossing the page cap should be left for the next call")
+
+	msgs, _, err = ch.Messages(ctx, 2, 3, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{4, 7}, messageIDs(msgs))
+	assert.Equal(t, []domain.MessageID{4, 5, 6}, msgs[0].AlbumIDs)
+}
+
+func TestChannel_Album(t *testing.T) {
+	ctx := context.Background()
+	history := waterPosts(1, 2, 3)
+	for _, obj := range history {
+		obj.(*tg.Message).SetGroupedID(77)
+	}
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil, history...)
+	ch, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+
+	album, ok, err := ch.Album(ctx, []domain.MessageID{3, 1, 2})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, domain.MessageID(1), album.ID)
+	assert.Equal(t, []domain.MessageID{1, 2, 3}, album.AlbumIDs)
+
+	api.Delete(waterChannel.ID, 1, 2)
+	album, ok, err = ch.Album(ctx, []domain.MessageID{1, 2, 3})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []domain.MessageID{3}, album.AlbumIDs, "deleted parts should be left out")
+
+	api.Delete(waterChannel.ID, 3)
+	_, ok, err = ch.Album(ctx, []domain.MessageID{1, 2, 3})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestChannel_ResolvesFromCache(t *testing.T) {
+	ctx := context.Background()
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil)
+	cache := mapPeerCache{}
+
+	_, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, cache)
+	require.NoError(t, err)
+	ch, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, cache)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), ch.Id())
+	assert.Equal(t, 1, api.Calls(&tg.ContactsResolveUsernameRequest{}), "cached username should not be resolved again")
+
+	_, err = NewChannel(ctx, api, "@unknown", domain.Supplier{Type: "gas"}, cache)
+	assert.True(t, tgerr.Is(err, "USERNAME_NOT_OCCUPIED"))
+}
+
+func TestChannel_FloodWaitFromFakeAPI(t *testing.T) {
+	ctx := context.Background()
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil, waterPosts(1, 2)...)
+	f, waits := testFloodWait(time.Minute, 3)
+
+	ch, err := NewChannel(ctx, f.Handle(api), "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+
+	api.Fail(&tg.MessagesGetHistoryRequest{}, tgfake.FloodWait(2))
+	msgs, _, err := ch.Messages(ctx, 10, 0, 1)
+	require.NoError(t, err, "FLOOD_WAIT should be waited out")
+	assert.Equal(t, []domain.MessageID{1, 2}, messageIDs(msgs))
+	assert.Equal(t, []time.Duration{3 * time.Second}, *waits)
+
+	api.Fail(&tg.MessagesGetHistoryRequest{}, tgfake.FloodWait(3600))
+	_, _, err = ch.Messages(ctx, 10, 0, 1)
+	_, ok := tgerr.AsFloodWait(err)
+	assert.True(t, ok, "waits over the limit should fail the call")
+}
+
+func TestChannel_ErrorsFromFakeAPI(t *testing.T) {
+	ctx := context.Background()
+	api := tgfake.New()
+	api.AddChannel(waterChannel, nil, waterPosts(1, 2, 3)...)
+	ch, err := NewChannel(ctx, api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+
+	api.Fail(&tg.MessagesGetHistoryRequest{}, tgfake.Error(400, "CHANNEL_PRIVATE"))
+	_, _, err = ch.Messages(ctx, 10, 1, 10)
+	assert.True(t, tgerr.Is(err, "CHANNEL_PRIVATE"))
+
+	api.Delete(waterChannel.ID, 2)
+	missing, err := ch.Missing(ctx, []domain.MessageID{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MessageID{2}, missing)
+}
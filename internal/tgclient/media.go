@@ -0,0 +1,180 @@
+package tgclient
+
+import (
+	"bytes"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// attachmentsOf describes message media as attachments, unsupported media gives none.
+func attachmentsOf(media tg.MessageMediaClass) []domain.Attachment {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		if photo, ok := m.Photo.(*tg.Photo); ok {
+			return []domain.Attachment{photoAttachment(photo)}
+		}
+	case *tg.MessageMediaDocument:
+		if doc, ok := m.Document.(*tg.Document); ok {
+			return []domain.Attachment{documentAttachment(doc)}
+		}
+	case *tg.MessageMediaWebPage:
+		if page, ok := m.Webpage.(*tg.WebPage); ok {
+			return []domain.Attachment{webPageAttachment(page)}
+		}
+	}
+	return nil
+}
+
+// photoAttachment describes the largest size of the photo.
+func photoAttachment(photo *tg.Photo) domain.Attachment {
+	att := domain.Attachment{
+		Kind:     domain.AttachmentPhoto,
+		MimeType: "image/jpeg",
+	}
+
+	var thumbSize string
+	for _, s := range photo.Sizes {
+		var (
+			typ  string
+			w, h int
+			size int
+		)
+		switch ps := s.(type) {
+		case *tg.PhotoSize:
+			typ, w, h, size = ps.Type, ps.W, ps.H, ps.Size
+		case *tg.PhotoSizeProgressive:
+			typ, w, h = ps.Type, ps.W, ps.H
+			if len(ps.Sizes) > 0 {
+				size = ps.Sizes[len(ps.Sizes)-1]
+			}
+		default:
+			// cached and stripped sizes are tiny previews embedded into the message
+			continue
+		}
+		if w*h > att.Width*att.Height {
+			thumbSize = typ
+			att.Width, att.Height, att.Size = w, h, int64(size)
+		}
+	}
+
+	att.File = &domain.FileReference{
+		ID:            photo.ID,
+		AccessHash:    photo.AccessHash,
+		FileReference: photo.FileReference,
+		DCID:          photo.DCID,
+		ThumbSize:     thumbSize,
+	}
+	return att
+}
+
+func documentAttachment(doc *tg.Document) domain.Attachment {
+	att := domain.Attachment{
+		Kind:     domain.AttachmentDocument,
+		MimeType: doc.MimeType,
+		Size:     doc.Size,
+		File: &domain.FileReference{
+			ID:            doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+			DCID:          doc.DCID,
+		},
+	}
+	for _, attr := range doc.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeFilename:
+			att.FileName = a.FileName
+		case *tg.DocumentAttributeImageSize:
+			att.Width, att.Height = a.W, a.H
+		case *tg.DocumentAttributeVideo:
+			att.Width, att.Height = a.W, a.H
+		}
+	}
+	return att
+}
+
+// webPageAttachment describes link preview, with its photo or document file when there is one.
+func webPageAttachment(page *tg.WebPage) domain.Attachment {
+	var att domain.Attachment
+	if doc, ok := page.Document.(*tg.Document); ok {
+		att = documentAttachment(doc)
+	} else if photo, ok := page.Photo.(*tg.Photo); ok {
+		att = photoAttachment(photo)
+	}
+	att.Kind = domain.AttachmentWebPage
+	att.URL = page.URL
+	att.Title = page.Title
+	return att
+}
+
+// placesOf fills location, venue and poll of the message from its media.
+func placesOf(msg *domain.Message, media tg.MessageMediaClass) {
+	switch m := media.(type) {
+	case *tg.MessageMediaGeo:
+		msg.Geo = geoPointOf(m.Geo)
+	case *tg.MessageMediaGeoLive:
+		msg.Geo = geoPointOf(m.Geo)
+		if msg.Geo != nil {
+			msg.Geo.Live = true
+		}
+	case *tg.MessageMediaVenue:
+		venue := &domain.Venue{
+			Title:     m.Title,
+			Address:   m.Address,
+			Provider:  m.Provider,
+			VenueID:   m.VenueID,
+			VenueType: m.VenueType,
+		}
+		if geo := geoPointOf(m.Geo); geo != nil {
+			venue.Geo = *geo
+		}
+		msg.Venue = venue
+	case *tg.MessageMediaPoll:
+		msg.Poll = pollOf(m)
+	}
+}
+
+// geoPointOf returns nil for empty point.
+func geoPointOf(geo tg.GeoPointClass) *domain.GeoPoint {
+	point, ok := geo.(*tg.GeoPoint)
+	if !ok {
+		return nil
+	}
+	return &domain.GeoPoint{
+		Latitude:       point.Lat,
+		Longitude:      point.Long,
+		AccuracyRadius: point.AccuracyRadius,
+	}
+}
+
+func pollOf(media *tg.MessageMediaPoll) *domain.Poll {
+	poll := &domain.Poll{
+		ID:             media.Poll.ID,
+		Question:       media.Poll.Question.Text,
+		Closed:         media.Poll.Closed,
+		Quiz:           media.Poll.Quiz,
+		MultipleChoice: media.Poll.MultipleChoice,
+		PublicVoters:   media.Poll.PublicVoters,
+		TotalVoters:    media.Results.TotalVoters,
+	}
+	if closeDate, ok := media.Poll.GetCloseDate(); ok {
+		closes := time.Unix(int64(closeDate), 0).UTC()
+		poll.CloseDate = &closes
+	}
+
+	for _, answer := range media.Poll.Answers {
+		result := domain.PollAnswer{
+			Text:   answer.Text.Text,
+			Option: answer.Option,
+		}
+		for _, voters := range media.Results.Results {
+			if bytes.Equal(voters.Option, answer.Option) {
+				result.Voters = voters.Voters
+				result.Correct = voters.Correct
+			}
+		}
+		poll.Answers = append(poll.Answers, result)
+	}
+	return poll
+}
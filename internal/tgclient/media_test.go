@@ -0,0 +1,206 @@
+package tgclient
+
+import (
+	"encoding/json"
+	"testing"
+	"tg-bridge/internal/domain"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachmentsOf(t *testing.T) {
+	photo := &tg.Photo{
+		ID:            1,
+		AccessHash:    2,
+		FileReference: []byte{3},
+		DCID:          4,
+		Sizes: []tg.PhotoSizeClass{
+			&tg.PhotoStrippedSize{Type: "i", Bytes: []byte{1}},
+			&tg.PhotoSize{Type: "m", W: 320, H: 240, Size: 1000},
+			&tg.PhotoSizeProgressive{Type: "y", W: 1280, H: 960, Sizes: []int{100, 5000, 90000}},
+			&tg.PhotoSize{Type: "x", W: 800, H: 600, Size: 40000},
+		},
+	}
+	pdf := &tg.Document{
+		ID:            10,
+		AccessHash:    20,
+		FileReference: []byte{30},
+		DCID:          2,
+		MimeType:      "application/pdf",
+		Size:          123456,
+		Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeFilename{FileName: "schedule.pdf"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		media tg.MessageMediaClass
+		want  []domain.Attachment
+	}{
+		{
+			name:  "photo_largest_size",
+			media: &tg.MessageMediaPhoto{Photo: photo},
+			want: []domain.Attachment{{
+				Kind:     domain.AttachmentPhoto,
+				MimeType: "image/jpeg",
+				Size:     90000,
+				Width:    1280,
+				Height:   960,
+				File:     &domain.FileReference{ID: 1, AccessHash: 2, FileReference: []byte{3}, DCID: 4, ThumbSize: "y"},
+			}},
+		},
+		{
+			name:  "document",
+			media: &tg.MessageMediaDocument{Document: pdf},
+			want: []domain.Attachment{{
+				Kind:     domain.AttachmentDocument,
+				MimeType: "application/pdf",
+				Size:     123456,
+				FileName: "schedule.pdf",
+				File:     &domain.FileReference{ID: 10, AccessHash: 20, FileReference: []byte{30}, DCID: 2},
+			}},
+		},
+		{
+			name: "image_document",
+			media: &tg.MessageMediaDocument{Document: &tg.Document{
+				ID:       11,
+				MimeType: "image/png",
+				Size:     500,
+				Attributes: []tg.DocumentAttributeClass{
+					&tg.DocumentAttributeImageSize{W: 1024, H: 768},
+					&tg.DocumentAttributeFilename{FileName: "map.png"},
+				},
+			}},
+			want: []domain.Attachment{{
+				Kind:     domain.AttachmentDocument,
+				MimeType: "image/png",
+				Size:     500,
+				FileName: "map.png",
+				Width:    1024,
+				Height:   768,
+				File:     &domain.FileReference{ID: 11},
+			}},
+		},
+		{
+			name: "web_page_with_photo",
+			media: &tg.MessageMediaWebPage{Webpage: &tg.WebPage{
+				URL:   "https://example.com/outages",
+				Title: "Planned outages",
+				Photo: photo,
+			}},
+			want: []domain.Attachment{{
+				Kind:     domain.AttachmentWebPage,
+				MimeType: "image/jpeg",
+				Size:     90000,
+				Width:    1280,
+				Height:   960,
+				URL:      "https://example.com/outages",
+				Title:    "Planned outages",
+				File:     &domain.FileReference{ID: 1, AccessHash: 2, FileReference: []byte{3}, DCID: 4, ThumbSize: "y"},
+			}},
+		},
+		{
+			name:  "pending_web_page",
+			media: &tg.MessageMediaWebPage{Webpage: &tg.WebPagePending{ID: 1}},
+			want:  nil,
+		},
+		{
+			name:  "unsupported",
+			media: &tg.MessageMediaDice{Value: 6, Emoticon: "🎲"},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, attachmentsOf(tt.media))
+		})
+	}
+}
+
+func TestAttachmentsSerialized(t *testing.T) {
+	msg := domain.Message{
+		ID:     1,
+		ChatID: 2,
+		Attachments: []domain.Attachment{{
+			Kind:     domain.AttachmentDocument,
+			MimeType: "application/pdf",
+			FileName: "schedule.pdf",
+		}},
+	}
+
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, []any{map[string]any{
+		"kind":      "document",
+		"mime_type": "application/pdf",
+		"file_name": "schedule.pdf",
+	}}, decoded["attachments"])
+}
+
+func TestPlacesOf(t *testing.T) {
+	point := &tg.GeoPoint{Lat: 46.84, Long: 29.63, AccuracyRadius: 50}
+
+	var geo domain.Message
+	placesOf(&geo, &tg.MessageMediaGeo{Geo: point})
+	assert.Equal(t, &domain.GeoPoint{Latitude: 46.84, Longitude: 29.63, AccuracyRadius: 50}, geo.Geo)
+
+	var live domain.Message
+	placesOf(&live, &tg.MessageMediaGeoLive{Geo: point, Period: 900})
+	require.NotNil(t, live.Geo)
+	assert.True(t, live.Geo.Live)
+
+	var empty domain.Message
+	placesOf(&empty, &tg.MessageMediaGeo{Geo: &tg.GeoPointEmpty{}})
+	assert.Nil(t, empty.Geo)
+
+	var venue domain.Message
+	placesOf(&venue, &tg.MessageMediaVenue{
+		Geo:      point,
+		Title:    "Pump station #3",
+		Address:  "Lenin st. 10",
+		Provider: "foursquare",
+		VenueID:  "4b5",
+	})
+	assert.Equal(t, &domain.Venue{
+		Geo:      domain.GeoPoint{Latitude: 46.84, Longitude: 29.63, AccuracyRadius: 50},
+		Title:    "Pump station #3",
+		Address:  "Lenin st. 10",
+		Provider: "foursquare",
+		VenueID:  "4b5",
+	}, venue.Venue)
+}
+
+func TestPlacesOf_Poll(t *testing.T) {
+	poll := tg.Poll{
+		ID:       7,
+		Question: tg.TextWithEntities{Text: "Was the water back on time?"},
+		Answers: []tg.PollAnswer{
+			{Text: tg.TextWithEntities{Text: "Yes"}, Option: []byte{0}},
+			{Text: tg.TextWithEntities{Text: "No"}, Option: []byte{1}},
+		},
+	}
+	poll.SetCloseDate(1700003600)
+	results := tg.PollResults{TotalVoters: 30}
+	results.SetResults([]tg.PollAnswerVoters{{Option: []byte{1}, Voters: 18}, {Option: []byte{0}, Voters: 12}})
+
+	var msg domain.Message
+	placesOf(&msg, &tg.MessageMediaPoll{Poll: poll, Results: results})
+
+	require.NotNil(t, msg.Poll)
+	assert.Equal(t, int64(7), msg.Poll.ID)
+	assert.Equal(t, "Was the water back on time?", msg.Poll.Question)
+	assert.Equal(t, 30, msg.Poll.TotalVoters)
+	assert.Equal(t, []domain.PollAnswer{
+		{Text: "Yes", Option: []byte{0}, Voters: 12},
+		{Text: "No", Option: []byte{1}, Voters: 18},
+	}, msg.Poll.Answers)
+	require.NotNil(t, msg.Poll.CloseDate)
+	assert.Equal(t, int64(1700003600), msg.Poll.CloseDate.Unix())
+}
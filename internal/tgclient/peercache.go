@@ -0,0 +1,164 @@
+package tgclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// PeerCache keeps resolved chats between restarts, resolving usernames is heavily rate limited by Telegram.
+type PeerCache interface {
+	GetResolvedPeer(ref string) (domain.Peer, bool, error)
+	SaveResolvedPeer(ref string, peer domain.Peer) error
+	DeleteResolvedPeer(ref string) error
+}
+
+var errStalePeer = errors.New("cached peer is no longer accessible")
+
+// resolveCached returns the cached chat of ref after checking it is still valid. The chat stays bound
+// to its id, so a renamed channel is still found by its old username; the old username is returned then.
+// Invalid chats are resolved again and cached. cache may be nil.
+func resolveCached(ctx context.Context, api *tg.Client, ref ChannelRef, cache PeerCache) (tg.ChatClass, string, error) {
+	if cache == nil {
+		chat, err := resolveChat(ctx, api, ref)
+		return chat, "", err
+	}
+
+	key := ref.key()
+	peer, ok, err := cache.GetResolvedPeer(key)
+	if err != nil {
+		log.Printf("peer cache read error for %s: %v", key, err)
+	}
+	if ok {
+		chat, err := refreshPeer(ctx, api, peer)
+		switch {
+		case err == nil:
+			if !ref.matches(chat) {
+				log.Printf("⚠️ %s is bound to chat %d which is now @%s, update the channel settings",
+					key, peer.ChatID, peerOf(chat, time.Time{}).Username)
+			}
+			oldUsername, renamed := renamedFrom(peer, chat)
+			if renamed {
+				// keep the original resolution time, only the username is refreshed
+				if err := cache.SaveResolvedPeer(key, peerOf(chat, peer.ResolvedAt)); err != nil {
+					log.Printf("peer cache write error for %s: %v", key, err)
+				}
+			}
+			return chat, oldUsername, nil
+		case errors.Is(err, errStalePeer) || tgerr.Is(err,
+			tg.ErrChannelInvalid, tg.ErrChannelPrivate, tg.ErrChatIDInvalid, tg.ErrPeerIDInvalid):
+			log.Printf("cached chat %d is not valid, resolving %s again: %v", peer.ChatID, key, err)
+		default:
+			return nil, "", fmt.Errorf("failed to check cached chat %d: %w", peer.ChatID, err)
+		}
+		if err := cache.DeleteResolvedPeer(key); err != nil {
+			log.Printf("peer cache delete error for %s: %v", key, err)
+		}
+	}
+
+	chat, err := resolveChat(ctx, api, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := cache.SaveResolvedPeer(key, peerOf(chat, time.Now().UTC())); err != nil {
+		log.Printf("peer cache write error for %s: %v", key, err)
+	}
+	return chat, "", nil
+}
+
+// renamedFrom returns the cached username if the chat no longer has it.
+func renamedFrom(peer domain.Peer, chat tg.ChatClass) (string, bool) {
+	current := peerOf(chat, time.Time{}).Username
+	if peer.Username == "" || strings.EqualFold(peer.Username, current) {
+		return "", false
+	}
+	return peer.Username, true
+}
+
+// refreshPeer fetches the current state of the cached chat by its id and access hash,
+// which is much cheaper than resolving username.
+func refreshPeer(ctx context.Context, api *tg.Client, peer domain.Peer) (tg.ChatClass, error) {
+	var (
+		res tg.MessagesChatsClass
+		err error
+	)
+	switch peer.Kind {
+	case domain.PeerChannel:
+		res, err = api.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+			&tg.InputChannel{ChannelID: int64(peer.ChatID), AccessHash: peer.AccessHash},
+		})
+	case domain.PeerChat:
+		res, err = api.MessagesGetChats(ctx, []int64{int64(peer.ChatID)})
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", errStalePeer, peer.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chat := range res.GetChats() {
+		if chat.GetID() != int64(peer.ChatID) {
+			continue
+		}
+		if chat, err := asChat(chat, strconv.FormatInt(chat.GetID(), 10)); err == nil {
+			return chat, nil
+		}
+	}
+	return nil, errStalePeer
+}
+
+// peerOf describes resolved chat for the cache.
+func peerOf(chat tg.ChatClass, resolvedAt time.Time) domain.Peer {
+	peer := domain.Peer{ChatID: domain.ChatID(chat.GetID()), ResolvedAt: resolvedAt}
+	switch chat := chat.(type) {
+	case *tg.Channel:
+		peer.Kind = domain.PeerChannel
+		peer.AccessHash = chat.AccessHash
+		peer.Forum = chat.Forum
+		peer.Username = chat.Username
+		peer.Title = chat.Title
+	case *tg.Chat:
+		peer.Kind = domain.PeerChat
+		peer.Title = chat.Title
+	}
+	return peer
+}
+
+// key identifies the chat of ref in the cache, topic is not part of it.
+func (r ChannelRef) key() string {
+	switch {
+	case r.ID != 0:
+		return strconv.FormatInt(r.ID, 10)
+	case r.InviteHash != "":
+		return "+" + r.InviteHash
+	}
+	return "@" + strings.ToLower(r.Username)
+}
+
+// matches reports whether the chat still has the username of ref, other refs always match.
+func (r ChannelRef) matches(chat tg.ChatClass) bool {
+	if r.Username == "" {
+		return true
+	}
+	channel, ok := chat.(*tg.Channel)
+	if !ok {
+		return false
+	}
+	if strings.EqualFold(channel.Username, r.Username) {
+		return true
+	}
+	for _, username := range channel.Usernames {
+		if username.Active && strings.EqualFold(username.Username, r.Username) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerOf(t *testing.T) {
+	now := time.Now().UTC()
+
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Forum: true, Username: "citygroup", Title: "City"}
+	assert.Equal(t, domain.Peer{
+		ChatID:     100,
+		AccessHash: 555,
+		Kind:       domain.PeerChannel,
+		Forum:      true,
+		Username:   "citygroup",
+		Title:      "City",
+		ResolvedAt: now,
+	}, peerOf(channel, now))
+
+	assert.Equal(t, domain.Peer{
+		ChatID:     300,
+		Kind:       domain.PeerChat,
+		Title:      "Street",
+		ResolvedAt: now,
+	}, peerOf(&tg.Chat{ID: 300, Title: "Street"}, now))
+}
+
+func TestChannelRef_Key(t *testing.T) {
+	assert.Equal(t, "@citygroup", ChannelRef{Username: "CityGroup", TopicID: 3}.key())
+	assert.Equal(t, "1234567890", ChannelRef{ID: 1234567890}.key())
+	assert.Equal(t, "+AbCdEf", ChannelRef{InviteHash: "AbCdEf"}.key())
+}
+
+func TestChannelRef_Matches(t *testing.T) {
+	renamed := &tg.Channel{ID: 100, Username: "newname"}
+	renamed.SetUsernames([]tg.Username{{Username: "alias", Active: true}, {Username: "oldname"}})
+
+	assert.True(t, ChannelRef{Username: "NewName"}.matches(renamed))
+	assert.True(t, ChannelRef{Username: "alias"}.matches(renamed))
+	assert.False(t, ChannelRef{Username: "oldname"}.matches(renamed), "inactive username points nowhere")
+	assert.True(t, ChannelRef{ID: 100}.matches(renamed), "id references survive renames")
+	assert.False(t, ChannelRef{Username: "newname"}.matches(&tg.Chat{ID: 300}))
+}
+
+func TestRenamedFrom(t *testing.T) {
+	cached := domain.Peer{ChatID: 100, Kind: domain.PeerChannel, Username: "oldname"}
+
+	old, renamed := renamedFrom(cached, &tg.Channel{ID: 100, Username: "newname"})
+	assert.True(t, renamed)
+	assert.Equal(t, "oldname", old)
+
+	_, renamed = renamedFrom(cached, &tg.Channel{ID: 100, Username: "OldName"})
+	assert.False(t, renamed, "usernames are case insensitive")
+
+	_, renamed = renamedFrom(domain.Peer{ChatID: 100, Kind: domain.PeerChannel}, &tg.Channel{ID: 100, Username: "public"})
+	assert.False(t, renamed, "private channel got a username")
+}
@@ -0,0 +1,372 @@
+package tgclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgsession"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/dcs"
+	"github.com/gotd/td/tgerr"
+)
+
+// accountErrors mean the session can't be used anymore, channels of the account move to other accounts.
+var accountErrors = []string{
+	"AUTH_KEY_UNREGISTERED",
+	"AUTH_KEY_DUPLICATED",
+	"SESSION_REVOKED",
+	"SESSION_EXPIRED",
+	"USER_DEACTIVATED",
+	"USER_DEACTIVATED_BAN",
+}
+
+// Account is a Telegram client of one session of the pool.
+type Account struct {
+	// Index is the position of the session in configuration
+	Index  int
+	Client *telegram.Client
+	// Updates of the account, nil when push mode is disabled
+	Updates *Updates
+
+	// guarded by Pool.mu
+	userID       int64
+	failure      error
+	blockedUntil time.Time
+	floodWaits   int
+}
+
+func (a *Account) String() string {
+	if a.userID == 0 {
+		return fmt.Sprintf("account %d", a.Index)
+	}
+	return fmt.Sprintf("account %d (user %d)", a.Index, a.userID)
+}
+
+// PoolOptions are settings of clients of the pool accounts.
+type PoolOptions struct {
+	// Updates receives updates of all accounts, updates are ignored if nil
+	Updates *Updates
+	// Middlewares returns middlewares for the client of each account, so each account has its own rate limits
+	Middlewares func() []telegram.Middleware
+	// MaxFloodWaits is the number of FLOOD_WAIT failures in a row after which channels leave the account
+	// until the wait is over, zero disables it
+	MaxFloodWaits int
+	// Resolver connects clients of all accounts to Telegram, directly if nil
+	Resolver dcs.Resolver
+	// TestDC connects all accounts to test DCs, sessions must be generated there
+	TestDC bool
+}
+
+// Pool runs a client per account and distributes channels across accounts. A channel stays on its
+// account until the account fails, then it moves to the available account with the fewest channels.
+type Pool struct {
+	accounts      []*Account
+	maxFloodWaits int
+	now           func() time.Time
+
+	mu       sync.Mutex
+	assigned map[domain.Supplier]*Account
+	channels map[domain.Supplier]*Channel
+}
+
+// NewPool creates clients of the sessions, they are connected by Run.
+func NewPool(apiID int, apiHash string, sessions []string, opts PoolOptions) (*Pool, error) {
+	if len(sessions) == 0 {
+		return nil, errors.New("no Telegram sessions")
+	}
+
+	accounts := make([]*Account, 0, len(sessions))
+	for i, s := range sessions {
+		if err := tgsession.CheckDCMode(s, opts.TestDC); err != nil {
+			return nil, fmt.Errorf("invalid session %d: %w", i, err)
+		}
+		storage, err := tgsession.CreateInMemorySessionStorage(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session %d: %w", i, err)
+		}
+
+		a := &Account{Index: i}
+		clientOpts := ClientOptions{Resolver: opts.Resolver, TestDC: opts.TestDC}
+		if opts.Updates != nil {
+			a.Updates = opts.Updates
+			if i > 0 {
+				a.Updates = opts.Updates.ForAccount()
+			}
+			clientOpts.UpdateHandler = a.Updates.Handler()
+		}
+		if opts.Middlewares != nil {
+			clientOpts.Middlewares = opts.Middlewares()
+		}
+		a.Client = CreateTelegramClient(apiID, apiHash, storage, clientOpts)
+		accounts = append(accounts, a)
+	}
+	return newPool(accounts, opts.MaxFloodWaits), nil
+}
+
+func newPool(accounts []*Account, maxFloodWaits int) *Pool {
+	return &Pool{
+		accounts:      accounts,
+		maxFloodWaits: maxFloodWaits,
+		now:           time.Now,
+		assigned:      make(map[domain.Supplier]*Account),
+		channels:      make(map[domain.Supplier]*Channel),
+	}
+}
+
+// Run connects clients of all accounts and calls f once each of them is connected or has failed,
+// failed accounts are not assigned channels. Updates of connected accounts are received until f returns.
+func (p *Pool) Run(ctx context.Context, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	// clients stop once ctx is canceled
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	started := make(chan struct{}, len(p.accounts))
+	for _, a := range p.accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var once sync.Once
+			start := func() { once.Do(func() { started <- struct{}{} }) }
+			defer start()
+
+			err := a.Client.Run(ctx, func(ctx context.Context) error {
+				if err := p.connect(ctx, a); err != nil {
+					return err
+				}
+				start()
+				if a.Updates != nil {
+					if err := a.Updates.Run(ctx, a.Client); err != nil && !errors.Is(err, context.Canceled) {
+						log.Printf("Telegram updates of %s failed, falling back to polling: %v", a, err)
+					}
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			if err != nil && ctx.Err() == nil {
+				p.fail(a, err)
+			}
+		}()
+	}
+	for range p.accounts {
+		select {
+		case <-started:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if p.Available() == 0 {
+		return errors.New("no Telegram account is connected")
+	}
+	return f(ctx)
+}
+
+func (p *Pool) connect(ctx context.Context, a *Account) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err := CheckTelegramSession(connectCtx, a.Client, func() error {
+		return errors.New("not authorized, session is not valid")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to Telegram: %w", err)
+	}
+	self, err := a.Client.Self(connectCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get self user: %w", err)
+	}
+
+	p.mu.Lock()
+	a.userID = self.ID
+	p.mu.Unlock()
+	log.Printf("Logged in %s as: %s", a, userName(self))
+	return nil
+}
+
+// Available returns the number of accounts channels may be assigned to.
+func (p *Pool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, a := range p.accounts {
+		if p.available(a) {
+			n++
+		}
+	}
+	return n
+}
+
+// Open resolves the channel on the account of the supplier. A supplier without an available account
+// is assigned the available account with the fewest channels, the next one is tried if the account fails
+// while resolving. Updates of the channel are delivered by its account.
+func (p *Pool) Open(ctx context.Context, supplier domain.Supplier, name string, cache PeerCache) (*Channel, error) {
+	for {
+		a, err := p.assign(supplier)
+		if err != nil {
+			return nil, err
+		}
+		ch, err := NewChannel(ctx, a.Client, name, supplier, p.cacheOf(a, cache))
+		if err != nil {
+			if p.observe(a, err) {
+				continue
+			}
+			return nil, err
+		}
+		p.track(supplier, a, ch)
+		return ch, nil
+	}
+}
+
+// Healthy reports whether the account of the supplier's channel is still available.
+func (p *Pool) Healthy(supplier domain.Supplier) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, ok := p.assigned[supplier]
+	return ok && p.available(a)
+}
+
+// Observe records the result of a call made for the channel of the supplier and reports whether the
+// channel has to move to another account, as its session is revoked or it keeps hitting FLOOD_WAIT.
+func (p *Pool) Observe(supplier domain.Supplier, err error) bool {
+	p.mu.Lock()
+	a, ok := p.assigned[supplier]
+	p.mu.Unlock()
+	if !ok {
+		return false
+	}
+	return p.observe(a, err)
+}
+
+func (p *Pool) observe(a *Account, err error) bool {
+	if err != nil && tgerr.Is(err, accountErrors...) {
+		p.fail(a, err)
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := tgerr.AsFloodWait(err)
+	if !ok {
+		if err == nil {
+			a.floodWaits = 0
+		}
+		return false
+	}
+	a.floodWaits++
+	if p.maxFloodWaits <= 0 || a.floodWaits < p.maxFloodWaits {
+		return false
+	}
+	a.floodWaits = 0
+	a.blockedUntil = p.now().Add(d)
+	log.Printf("⚠️ %s keeps hitting FLOOD_WAIT, its channels move to other accounts for %s", a, d)
+	return true
+}
+
+func (p *Pool) fail(a *Account, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if a.failure == nil {
+		log.Printf("⚠️ %s failed, its channels move to other accounts: %v", a, err)
+		a.failure = err
+	}
+}
+
+// available reports whether the account is connected, has not failed and is not waiting out FLOOD_WAIT.
+func (p *Pool) available(a *Account) bool {
+	return a.userID != 0 && a.failure == nil && !p.now().Before(a.blockedUntil)
+}
+
+// assign returns the account of the supplier, assigning the available one with the fewest channels
+// if the supplier has no account yet or its account is not available anymore.
+func (p *Pool) assign(supplier domain.Supplier) (*Account, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prev, ok := p.assigned[supplier]
+	if ok && p.available(prev) {
+		return prev, nil
+	}
+
+	load := make(map[*Account]int, len(p.accounts))
+	for s, a := range p.assigned {
+		if s != supplier {
+			load[a]++
+		}
+	}
+	var best *Account
+	for _, a := range p.accounts {
+		if p.available(a) && (best == nil || load[a] < load[best]) {
+			best = a
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no Telegram account is available")
+	}
+
+	if ok {
+		log.Printf("moving channel of %s supplier from %s to %s", supplier.Type, prev, best)
+	}
+	p.assigned[supplier] = best
+	return best, nil
+}
+
+// track moves delivery of updates of the supplier's channel to its account.
+func (p *Pool) track(supplier domain.Supplier, a *Account, ch *Channel) {
+	p.mu.Lock()
+	prev := p.channels[supplier]
+	p.channels[supplier] = ch
+	p.mu.Unlock()
+
+	if prev != nil {
+		for _, other := range p.accounts {
+			if other.Updates != nil {
+				other.Updates.Untrack(prev)
+			}
+		}
+	}
+	if a.Updates != nil {
+		a.Updates.Track(ch)
+	}
+}
+
+// cacheOf returns the peer cache of the account, access hashes of channels differ between accounts.
+// A single account shares the cache as is.
+func (p *Pool) cacheOf(a *Account, cache PeerCache) PeerCache {
+	if cache == nil || len(p.accounts) == 1 {
+		return cache
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return AccountPeerCache(cache, a.userID)
+}
+
+// AccountPeerCache returns the cache of peers resolved by the user, as kept by a pool of several accounts.
+func AccountPeerCache(cache PeerCache, userID int64) PeerCache {
+	return accountCache{cache: cache, prefix: fmt.Sprintf("%d:", userID)}
+}
+
+// accountCache keeps peers resolved by one account apart from the others.
+type accountCache struct {
+	cache  PeerCache
+	prefix string
+}
+
+func (c accountCache) GetResolvedPeer(ref string) (domain.Peer, bool, error) {
+	return c.cache.GetResolvedPeer(c.prefix + ref)
+}
+
+func (c accountCache) SaveResolvedPeer(ref string, peer domain.Peer) error {
+	return c.cache.SaveResolvedPeer(c.prefix+ref, peer)
+}
+
+func (c accountCache) DeleteResolvedPeer(ref string) error {
+	return c.cache.DeleteResolvedPeer(c.prefix + ref)
+}
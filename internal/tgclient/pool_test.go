@@ -0,0 +1,185 @@
+package tgclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgsession"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPool(maxFloodWaits int, userIDs ...int64) *Pool {
+	accounts := make([]*Account, len(userIDs))
+	for i, id := range userIDs {
+		accounts[i] = &Account{Index: i, userID: id}
+	}
+	return newPool(accounts, maxFloodWaits)
+}
+
+func TestPool_AssignsLeastLoadedAccount(t *testing.T) {
+	p := testPool(0, 1, 2)
+	water, gas, power := domain.Supplier{Type: "water"}, domain.Supplier{Type: "gas"}, domain.Supplier{Type: "power"}
+
+	a, err := p.assign(water)
+	require.NoError(t, err)
+	assert.Equal(t, 0, a.Index)
+	a, err = p.assign(gas)
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Index)
+	a, err = p.assign(power)
+	require.NoError(t, err)
+	assert.Equal(t, 0, a.Index)
+
+	a, err = p.assign(gas)
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Index, "channel should stay on its account")
+	assert.True(t, p.Healthy(gas))
+	assert.False(t, p.Healthy(domain.Supplier{Type: "heat"}), "supplier without account is not healthy")
+}
+
+func TestPool_FailsOverRevokedAccount(t *testing.T) {
+	p := testPool(0, 1, 2)
+	water, gas := domain.Supplier{Type: "water"}, domain.Supplier{Type: "gas"}
+	_, err := p.assign(water)
+	require.NoError(t, err)
+	_, err = p.assign(gas)
+	require.NoError(t, err)
+
+	assert.False(t, p.Observe(water, errors.New("network is unreachable")))
+	assert.True(t, p.Healthy(water))
+
+	assert.True(t, p.Observe(water, tgerr.New(401, "AUTH_KEY_UNREGISTERED")))
+	assert.False(t, p.Healthy(water))
+	assert.Equal(t, 1, p.Available())
+
+	a, err := p.assign(water)
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Index)
+	assert.True(t, p.Healthy(water))
+
+	assert.True(t, p.Observe(gas, tgerr.New(401, "SESSION_REVOKED")))
+	_, err = p.assign(gas)
+	assert.Error(t, err, "no account should be left")
+}
+
+func TestPool_MovesChannelsOffFloodedAccount(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := testPool(2, 1, 2)
+	p.now = func() time.Time { return now }
+	water := domain.Supplier{Type: "water"}
+	_, err := p.assign(water)
+	require.NoError(t, err)
+
+	floodWait := tgerr.New(420, "FLOOD_WAIT_60")
+	assert.False(t, p.Observe(water, floodWait))
+	assert.False(t, p.Observe(water, nil), "success should reset the count")
+	assert.False(t, p.Observe(water, floodWait))
+	assert.True(t, p.Observe(water, floodWait))
+	assert.False(t, p.Healthy(water))
+
+	a, err := p.assign(water)
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Index)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 2, p.Available(), "account should be back once the wait is over")
+}
+
+func TestPool_DisabledFloodWaitFailover(t *testing.T) {
+	p := testPool(0, 1, 2)
+	water := domain.Supplier{Type: "water"}
+	_, err := p.assign(water)
+	require.NoError(t, err)
+
+	for range 5 {
+		assert.False(t, p.Observe(water, tgerr.New(420, "FLOOD_WAIT_60")))
+	}
+	assert.True(t, p.Healthy(water))
+}
+
+func TestPool_TracksChannelOnItsAccount(t *testing.T) {
+	ctx := context.Background()
+	shared := NewUpdates(10, time.Minute)
+	p := newPool([]*Account{
+		{Index: 0, userID: 1, Updates: shared},
+		{Index: 1, userID: 2, Updates: shared.ForAccount()},
+	}, 0)
+	water := domain.Supplier{Type: "water"}
+
+	a, err := p.assign(water)
+	require.NoError(t, err)
+	p.track(water, a, testChannel(t, &tg.Channel{ID: 100}, 0, water))
+
+	p.fail(a, errors.New("connection closed"))
+	next, err := p.assign(water)
+	require.NoError(t, err)
+	p.track(water, next, testChannel(t, &tg.Channel{ID: 100}, 0, water))
+
+	update := &tg.UpdateNewChannelMessage{
+		Message: &tg.Message{ID: 7, PeerID: &tg.PeerChannel{ChannelID: 100}, Message: "outage", Date: 1700000000},
+	}
+	require.NoError(t, p.accounts[0].Updates.onNewChannelMessage(ctx, tg.Entities{}, update))
+	assert.Empty(t, shared.Messages(), "failed account should not deliver the channel anymore")
+
+	require.NoError(t, p.accounts[1].Updates.onNewChannelMessage(ctx, tg.Entities{}, update))
+	require.Len(t, shared.Messages(), 1, "accounts should share the streams")
+	assert.Equal(t, domain.MessageID(7), (<-shared.Messages()).ID)
+}
+
+type mapPeerCache map[string]domain.Peer
+
+func (c mapPeerCache) GetResolvedPeer(ref string) (domain.Peer, bool, error) {
+	p, ok := c[ref]
+	return p, ok, nil
+}
+
+func (c mapPeerCache) SaveResolvedPeer(ref string, peer domain.Peer) error {
+	c[ref] = peer
+	return nil
+}
+
+func (c mapPeerCache) DeleteResolvedPeer(ref string) error {
+	delete(c, ref)
+	return nil
+}
+
+func TestPool_CacheOf(t *testing.T) {
+	cache := mapPeerCache{}
+	single := testPool(0, 1)
+	assert.Equal(t, PeerCache(cache), single.cacheOf(single.accounts[0], cache))
+
+	p := testPool(0, 1, 2)
+	first, second := p.cacheOf(p.accounts[0], cache), p.cacheOf(p.accounts[1], cache)
+	require.NoError(t, first.SaveResolvedPeer("@citygroup", domain.Peer{ChatID: 100, AccessHash: 111}))
+	require.NoError(t, second.SaveResolvedPeer("@citygroup", domain.Peer{ChatID: 100, AccessHash: 222}))
+
+	peer, ok, err := first.GetResolvedPeer("@citygroup")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(111), peer.AccessHash)
+	assert.Equal(t, int64(222), cache["2:@citygroup"].AccessHash)
+
+	require.NoError(t, second.DeleteResolvedPeer("@citygroup"))
+	assert.Contains(t, cache, "1:@citygroup")
+	assert.NotContains(t, cache, "2:@citygroup")
+}
+
+func TestNewPool_RejectsSessionOfOtherDCs(t *testing.T) {
+	storage := tgsession.NewMemorySessionStorage(nil)
+	require.NoError(t, GenerateSampleSession(context.Background(), storage, true))
+	testSession, err := GetEncodedSession(context.Background(), storage)
+	require.NoError(t, err)
+
+	_, err = NewPool(1, "hash", []string{testSession}, PoolOptions{})
+	assert.ErrorContains(t, err, "test DCs")
+
+	p, err := NewPool(1, "hash", []string{testSession}, PoolOptions{TestDC: true})
+	require.NoError(t, err)
+	assert.Len(t, p.accounts, 1)
+}
@@ -0,0 +1,73 @@
+package tgclient
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/gotd/td/telegram/dcs"
+	"golang.org/x/net/proxy"
+)
+
+// NewResolver returns the resolver connecting to Telegram through the proxy given as URL, or the default
+// resolver if proxyURL is empty. Supported proxies:
+//   - socks5://[user:password@]host:port
+//   - mtproxy://host:port?secret=<hex or base64 secret>
+func NewResolver(proxyURL string) (dcs.Resolver, error) {
+	if proxyURL == "" {
+		return dcs.DefaultResolver(), nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Host == "" || u.Port() == "" {
+		return nil, fmt.Errorf("proxy URL %s://%s has no host:port", u.Scheme, u.Host)
+	}
+
+	switch u.Scheme {
+	case "socks5":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOCKS5 proxy: %w", err)
+		}
+		// SOCKS5 dialer of x/net always implements ContextDialer
+		return dcs.Plain(dcs.PlainOptions{Dial: dialer.(proxy.ContextDialer).DialContext}), nil
+	case "mtproxy":
+		secret, err := parseProxySecret(u.Query().Get("secret"))
+		if err != nil {
+			return nil, err
+		}
+		r, err := dcs.MTProxy(u.Host, secret, dcs.MTProxyOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid MTProxy secret: %w", err)
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected socks5 or mtproxy", u.Scheme)
+	}
+}
+
+// parseProxySecret decodes MTProxy secret as shared in proxy links, either hex or base64 encoded.
+func parseProxySecret(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.New("MTProxy secret is required")
+	}
+	if secret, err := hex.DecodeString(s); err == nil {
+		return secret, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.StdEncoding, base64.RawStdEncoding} {
+		if secret, err := enc.DecodeString(s); err == nil {
+			return secret, nil
+		}
+	}
+	return nil, errors.New("MTProxy secret is neither hex nor base64")
+}
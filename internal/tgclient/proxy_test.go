@@ -0,0 +1,214 @@
+package tgclient
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/proto/codec"
+	"github.com/gotd/td/telegram/dcs"
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socks5Server is a SOCKS5 stand-in accepting CONNECT with username/password authentication.
+type socks5Server struct {
+	user, password string
+	listener       net.Listener
+	targets        chan string
+}
+
+func newSocks5Server(t *testing.T, user, password string) *socks5Server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	s := &socks5Server{user: user, password: password, listener: l, targets: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *socks5Server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	// greeting: version, methods, only username/password method is accepted
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, head[1])); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 2}); err != nil {
+		return
+	}
+
+	// RFC 1929 authentication
+	user, password, err := readSocks5Auth(conn)
+	if err != nil {
+		return
+	}
+	if user != s.user || password != s.password {
+		_, _ = conn.Write([]byte{1, 1})
+		return
+	}
+	if _, err := conn.Write([]byte{1, 0}); err != nil {
+		return
+	}
+
+	target, err := readSocks5Target(conn)
+	if err != nil {
+		return
+	}
+	s.targets <- target
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func readSocks5Auth(r io.Reader) (string, string, error) {
+	readField := func() (string, error) {
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(r, n); err != nil {
+			return "", err
+		}
+		b := make([]byte, n[0])
+		_, err := io.ReadFull(r, b)
+		return string(b), err
+	}
+	if _, err := io.ReadFull(r, make([]byte, 1)); err != nil {
+		return "", "", err
+	}
+	user, err := readField()
+	if err != nil {
+		return "", "", err
+	}
+	password, err := readField()
+	return user, password, err
+}
+
+func readSocks5Target(r io.Reader) (string, error) {
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return "", err
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 4:
+		ip := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	default:
+		return "", fmt.Errorf("unsupported address type %d", req[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// fakeDC accepts connections and reports the transport tag each client starts with.
+func fakeDC(t *testing.T) (tg.DCOption, <-chan [4]byte) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	tags := make(chan [4]byte, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var tag [4]byte
+			if _, err := io.ReadFull(conn, tag[:]); err == nil {
+				tags <- tag
+			}
+			_ = conn.Close()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return tg.DCOption{ID: 2, IPAddress: addr.IP.String(), Port: addr.Port}, tags
+}
+
+func TestNewResolver_SOCKS5(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	proxy := newSocks5Server(t, "bridge", "s3cret")
+	dc, tags := fakeDC(t)
+	list := dcs.List{Options: []tg.DCOption{dc}}
+
+	r, err := NewResolver("socks5://bridge:s3cret@" + proxy.addr())
+	require.NoError(t, err)
+	conn, err := r.Primary(ctx, 2, list)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	assert.Equal(t, net.JoinHostPort(dc.IPAddress, strconv.Itoa(dc.Port)), <-proxy.targets,
+		"DC should be dialed through the proxy")
+	assert.Equal(t, codec.IntermediateClientStart, <-tags)
+
+	r, err = NewResolver("socks5://bridge:wrong@" + proxy.addr())
+	require.NoError(t, err)
+	_, err = r.Primary(ctx, 2, list)
+	assert.Error(t, err, "proxy should reject wrong credentials")
+}
+
+func TestNewResolver(t *testing.T) {
+	r, err := NewResolver("")
+	require.NoError(t, err)
+	assert.NotNil(t, r)
+
+	_, err = NewResolver("mtproxy://127.0.0.1:443?secret=dd00112233445566778899aabbccddeeff")
+	assert.NoError(t, err)
+	_, err = NewResolver("mtproxy://127.0.0.1:443?secret=ABEiM0RVZneImaq7zN3u_w")
+	assert.NoError(t, err, "base64 secret should be accepted")
+
+	for _, invalid := range []string{
+		"http://127.0.0.1:8080",
+		"socks5://127.0.0.1",
+		"mtproxy://127.0.0.1:443",
+		"mtproxy://127.0.0.1:443?secret=zz",
+		"mtproxy://127.0.0.1:443?secret=0011",
+	} {
+		_, err := NewResolver(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
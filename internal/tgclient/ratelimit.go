@@ -0,0 +1,164 @@
+package tgclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"golang.org/x/time/rate"
+)
+
+// Method classes share a rate limit, Telegram limits some methods much stricter than others.
+const (
+	MethodClassResolve  = "resolve"
+	MethodClassHistory  = "history"
+	MethodClassDownload = "download"
+	MethodClassDefault  = "default"
+)
+
+var methodClasses = map[uint32]string{
+	tg.ContactsResolveUsernameRequestTypeID:  MethodClassResolve,
+	tg.MessagesCheckChatInviteRequestTypeID:  MethodClassResolve,
+	tg.MessagesImportChatInviteRequestTypeID: MethodClassResolve,
+	tg.MessagesGetHistoryRequestTypeID:       MethodClassHistory,
+	tg.MessagesGetRepliesRequestTypeID:       MethodClassHistory,
+	tg.MessagesGetMessagesRequestTypeID:      MethodClassHistory,
+	tg.ChannelsGetMessagesRequestTypeID:      MethodClassHistory,
+	tg.UploadGetFileRequestTypeID:            MethodClassDownload,
+}
+
+// DefaultRateLimits are requests per second allowed for each method class.
+var DefaultRateLimits = map[string]float64{
+	MethodClassResolve:  0.2,
+	MethodClassHistory:  3,
+	MethodClassDownload: 10,
+	MethodClassDefault:  10,
+}
+
+// methodName returns TL name of the request, e.g. messages.getHistory.
+func methodName(input bin.Encoder) string {
+	if named, ok := input.(interface{ TypeName() string }); ok {
+		return named.TypeName()
+	}
+	return fmt.Sprintf("%T", input)
+}
+
+func methodClass(input bin.Encoder) string {
+	if typed, ok := input.(interface{ TypeID() uint32 }); ok {
+		if class, ok := methodClasses[typed.TypeID()]; ok {
+			return class
+		}
+	}
+	return MethodClassDefault
+}
+
+// FloodWait retries requests failed with FLOOD_WAIT after the time requested by Telegram. Waits
+// of a request are bounded by maxWait in total and by maxRetries, beyond them the error is returned
+// right away, so a flooded account fails calls and the pool can move its channels instead of the
+// caller being blocked for long.
+//
+// gotd/contrib/middleware/floodwait is not used: its max wait bounds each wait rather than
+// the total of a call, and its callback doesn't get the request to label metrics with.
+type FloodWait struct {
+	maxWait    time.Duration
+	maxRetries int
+	// onWait is called for each FLOOD_WAIT with the wait requested by Telegram, may be nil
+	onWait func(method string, d time.Duration)
+	after  func(d time.Duration) <-chan time.Time
+}
+
+func NewFloodWait(maxWait time.Duration, maxRetries int, onWait func(method string, d time.Duration)) *FloodWait {
+	return &FloodWait{
+		maxWait:    maxWait,
+		maxRetries: maxRetries,
+		onWait:     onWait,
+		after:      time.After,
+	}
+}
+
+// Handle implements telegram.Middleware.
+func (f *FloodWait) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		var waited time.Duration
+		for retry := 0; ; retry++ {
+			err := next.Invoke(ctx, input, output)
+			d, ok := tgerr.AsFloodWait(err)
+			if !ok {
+				return err
+			}
+			method := methodName(input)
+			if f.onWait != nil {
+				f.onWait(method, d)
+			}
+			// waits are rounded to seconds, an extra one avoids hitting the limit again right away
+			d += time.Second
+			if retry >= f.maxRetries || waited+d > f.maxWait {
+				return err
+			}
+
+			log.Printf("⏳ FLOOD_WAIT on %s, retrying in %s", method, d)
+			waited += d
+			select {
+			case <-f.after(d):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// RateLimit delays requests so each method class stays within its requests per second.
+type RateLimit struct {
+	limiters map[string]*rate.Limiter
+	// onWait is called after a request was delayed, may be nil
+	onWait func(class string, d time.Duration)
+}
+
+// NewRateLimit creates limiter with DefaultRateLimits overridden by limits.
+// A limit <= 0 disables limiting of the class.
+func NewRateLimit(limits map[string]float64, onWait func(class string, d time.Duration)) (*RateLimit, error) {
+	merged := make(map[string]float64, len(DefaultRateLimits))
+	for class, limit := range DefaultRateLimits {
+		merged[class] = limit
+	}
+	for class, limit := range limits {
+		if _, ok := DefaultRateLimits[class]; !ok {
+			return nil, fmt.Errorf("unknown method class %q", class)
+		}
+		merged[class] = limit
+	}
+
+	r := &RateLimit{
+		limiters: make(map[string]*rate.Limiter, len(merged)),
+		onWait:   onWait,
+	}
+	for class, limit := range merged {
+		if limit <= 0 {
+			continue
+		}
+		r.limiters[class] = rate.NewLimiter(rate.Limit(limit), max(1, int(limit)))
+	}
+	return r, nil
+}
+
+// Handle implements telegram.Middleware.
+func (r *RateLimit) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		class := methodClass(input)
+		if limiter, ok := r.limiters[class]; ok {
+			start := time.Now()
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			if waited := time.Since(start); waited > time.Millisecond && r.onWait != nil {
+				r.onWait(class, waited)
+			}
+		}
+		return next.Invoke(ctx, input, output)
+	}
+}
@@ -0,0 +1,120 @@
+package tgclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingInvoker fails first calls with errs, then succeeds.
+type failingInvoker struct {
+	errs  []error
+	calls int
+}
+
+func (f *failingInvoker) Invoke(_ context.Context, _ bin.Encoder, _ bin.Decoder) error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func floodWait(seconds string) error {
+	return tgerr.New(420, "FLOOD_WAIT_"+seconds)
+}
+
+func testFloodWait(maxWait time.Duration, maxRetries int) (*FloodWait, *[]time.Duration) {
+	var waits []time.Duration
+	f := NewFloodWait(maxWait, maxRetries, nil)
+	f.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	return f, &waits
+}
+
+func TestFloodWait_RetriesAfterWait(t *testing.T) {
+	f, waits := testFloodWait(time.Minute, 3)
+	next := &failingInvoker{errs: []error{floodWait("5"), floodWait("7")}}
+
+	var reported []time.Duration
+	f.onWait = func(method string, d time.Duration) {
+		assert.Equal(t, "contacts.resolveUsername", method)
+		reported = append(reported, d)
+	}
+
+	err := f.Handle(next)(context.Background(), &tg.ContactsResolveUsernameRequest{Username: "x"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, next.calls)
+	assert.Equal(t, []time.Duration{6 * time.Second, 8 * time.Second}, *waits)
+	assert.Equal(t, []time.Duration{5 * time.Second, 7 * time.Second}, reported, "waits requested by Telegram should be reported")
+}
+
+func TestFloodWait_GivesUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+	}{
+		{name: "too_long", errs: []error{floodWait("3600")}, wantCalls: 1},
+		{name: "too_long_in_total", errs: []error{floodWait("40"), floodWait("30")}, wantCalls: 2},
+		{name: "too_many_retries", errs: []error{floodWait("1"), floodWait("1"), floodWait("1")}, wantCalls: 3},
+		{name: "other_error", errs: []error{tgerr.New(400, "CHANNEL_INVALID")}, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := testFloodWait(time.Minute, 2)
+			next := &failingInvoker{errs: tt.errs}
+
+			err := f.Handle(next)(context.Background(), &tg.MessagesGetHistoryRequest{}, nil)
+			assert.Error(t, err)
+			assert.Equal(t, tt.wantCalls, next.calls)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var delayed []string
+	r, err := NewRateLimit(map[string]float64{MethodClassResolve: 4, MethodClassHistory: 0}, func(class string, _ time.Duration) {
+		delayed = append(delayed, class)
+	})
+	require.NoError(t, err)
+
+	next := &failingInvoker{}
+	invoke := r.Handle(next)
+	// a second worth of calls passes at once, the next one waits
+	for i := 0; i < 5; i++ {
+		require.NoError(t, invoke(context.Background(), &tg.ContactsResolveUsernameRequest{}, nil))
+		require.NoError(t, invoke(context.Background(), &tg.MessagesGetHistoryRequest{}, nil))
+	}
+	assert.Equal(t, 10, next.calls)
+	assert.Equal(t, []string{MethodClassResolve}, delayed)
+
+	_, err = NewRateLimit(map[string]float64{"unknown": 1}, nil)
+	assert.Error(t, err)
+}
+
+func TestRateLimit_StopsOnCancel(t *testing.T) {
+	r, err := NewRateLimit(map[string]float64{MethodClassDefault: 0.001}, nil)
+	require.NoError(t, err)
+	invoke := r.Handle(&failingInvoker{})
+
+	// the first call takes the only token
+	require.NoError(t, invoke(context.Background(), &tg.HelpGetConfigRequest{}, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = invoke(ctx, &tg.HelpGetConfigRequest{}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
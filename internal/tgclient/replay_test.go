@@ -0,0 +1,87 @@
+package tgclient
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+	"tg-bridge/internal/domain"
+	"tg-bridge/internal/tgfake"
+	"tg-bridge/internal/tgrecord"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Cassettes in testdata are replayed by tests. Captures of real channels made with TELEGRAM_RECORD_FILE
+// may be dropped there as well, -update rewrites the ones scripted below.
+var update = flag.Bool("update", false, "rewrite scripted cassettes in testdata")
+
+const historyCassette = "testdata/history.tl"
+
+// recordHistory scripts history of a channel with an edited post, an album and a pinned message and
+// records reading it into the cassette.
+func recordHistory(t *testing.T, path string) {
+	t.Helper()
+	photo := func(id int64) *tg.MessageMediaPhoto {
+		media := &tg.MessageMediaPhoto{}
+		media.SetPhoto(&tg.Photo{
+			ID:            id,
+			AccessHash:    id * 10,
+			FileReference: []byte{1, 2, 3},
+			Date:          1700000100,
+			Sizes:         []tg.PhotoSizeClass{&tg.PhotoSize{Type: "y", W: 1280, H: 960, Size: 204800}},
+			DCID:          2,
+		})
+		return media
+	}
+	edited := &tg.Message{ID: 10, Message: "Planned outage on Main st. from 10:00 to 14:00", Date: 1700000000}
+	edited.SetEditDate(1700000600)
+	album1 := &tg.Message{ID: 11, Message: "Repair works", Date: 1700000100, Media: photo(501)}
+	album1.SetGroupedID(777)
+	album2 := &tg.Message{ID: 12, Date: 1700000100, Media: photo(502)}
+	album2.SetGroupedID(777)
+	pin := &tg.MessageService{ID: 13, Date: 1700000700, Action: &tg.MessageActionPinMessage{}}
+	pin.SetReplyTo(&tg.MessageReplyHeader{ReplyToMsgID: 10})
+
+	fake := tgfake.New()
+	fake.AddChannel(waterChannel, nil, edited, album1, album2, pin)
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, f.Close()) }()
+	recorder := tgrecord.NewRecorder(f, tgrecord.HistoryMethods...)
+
+	ch, err := NewChannel(context.Background(), recorder.Handle(fake), "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+	_, _, err = ch.Messages(context.Background(), 10, 0, 1)
+	require.NoError(t, err)
+}
+
+func TestChannel_MessagesFromRecordedHistory(t *testing.T) {
+	if *update {
+		recordHistory(t, historyCassette)
+	}
+	records, err := tgrecord.Load(historyCassette)
+	require.NoError(t, err)
+	api := tgfake.New()
+	api.Replay(records...)
+
+	ch, err := NewChannel(context.Background(), api, "@waterutility", domain.Supplier{Type: "water"}, nil)
+	require.NoError(t, err)
+	msgs, events, err := ch.Messages(context.Background(), 10, 0, 1)
+	require.NoError(t, err)
+
+	require.Len(t, msgs, 2)
+	assert.Equal(t, domain.MessageID(10), msgs[0].ID)
+	require.NotNil(t, msgs[0].EditDate, "edited post should carry its edit date")
+	assert.Equal(t, int64(1700000600), msgs[0].EditDate.Unix())
+
+	assert.Equal(t, []domain.MessageID{11, 12}, msgs[1].AlbumIDs, "album parts should be merged")
+	assert.Equal(t, "Repair works", msgs[1].Text)
+	assert.Len(t, msgs[1].Attachments, 2)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, domain.MessageID(13), events[0].ID)
+}
@@ -0,0 +1,111 @@
+package tgclient
+
+import (
+	"context"
+	"slices"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// maxMessageIDs is the max number of messages Telegram returns by ids at once.
+const maxMessageIDs = 100
+
+// ResolveReplies embeds text of replied messages into msgs. Messages replied to within msgs are
+// taken from them, the rest are fetched in batches. Replies within the discussion group of followed
+// comments are resolved as well, replies to other chats are left as is.
+func (c *Channel) ResolveReplies(ctx context.Context, msgs []domain.Message) error {
+	if err := c.resolveReplies(ctx, msgs); err != nil {
+		return err
+	}
+	if c.discussion != nil {
+		return c.discussion.resolveReplies(ctx, msgs)
+	}
+	return nil
+}
+
+func (c *Channel) resolveReplies(ctx context.Context, msgs []domain.Message) error {
+	texts := knownTexts(msgs, domain.ChatID(c.id))
+	for batch := range slices.Chunk(unresolvedReplies(msgs, domain.ChatID(c.id), texts), maxMessageIDs) {
+		fetched, _, err := c.messagesByID(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, obj := range fetched {
+			if msg, ok := obj.(*tg.Message); ok {
+				texts[domain.MessageID(msg.ID)] = msg.Message
+			}
+		}
+	}
+	embedReplies(msgs, domain.ChatID(c.id), texts)
+	return nil
+}
+
+// knownTexts maps ids of msgs of the chat to their text, all parts of an album share the caption.
+func knownTexts(msgs []domain.Message, chatID domain.ChatID) map[domain.MessageID]string {
+	texts := make(map[domain.MessageID]string, len(msgs))
+	for _, m := range msgs {
+		if m.ChatID != chatID {
+			continue
+		}
+		texts[m.ID] = m.Text
+		for _, id := range m.AlbumIDs {
+			texts[id] = m.Text
+		}
+	}
+	return texts
+}
+
+// unresolvedReplies returns distinct ids of messages of the chat replied to by msgs and missing in texts.
+func unresolvedReplies(msgs []domain.Message, chatID domain.ChatID, texts map[domain.MessageID]string) []domain.MessageID {
+	var ids []domain.MessageID
+	for _, m := range msgs {
+		if m.ReplyTo == nil || m.ReplyTo.ChatID != chatID {
+			continue
+		}
+		if _, ok := texts[m.ReplyTo.ID]; ok || slices.Contains(ids, m.ReplyTo.ID) {
+			continue
+		}
+		ids = append(ids, m.ReplyTo.ID)
+	}
+	return ids
+}
+
+func embedReplies(msgs []domain.Message, chatID domain.ChatID, texts map[domain.MessageID]string) {
+	for i := range msgs {
+		reply := msgs[i].ReplyTo
+		if reply == nil || reply.ChatID != chatID {
+			continue
+		}
+		reply.Text = texts[reply.ID]
+	}
+}
+
+// forwardOf describes the origin of a forwarded message.
+func forwardOf(fwd tg.MessageFwdHeader) *domain.ForwardOrigin {
+	origin := &domain.ForwardOrigin{
+		FromName:   fwd.FromName,
+		MessageID:  domain.MessageID(fwd.ChannelPost),
+		Date:       time.Unix(int64(fwd.Date), 0).UTC(),
+		PostAuthor: fwd.PostAuthor,
+	}
+	switch from := fwd.FromID.(type) {
+	case *tg.PeerUser:
+		origin.UserID = domain.UserID(from.UserID)
+	case *tg.PeerChannel, *tg.PeerChat:
+		origin.ChatID = chatIDOf(from)
+	}
+	return origin
+}
+
+// chatIDOf returns id of a channel or a group, zero for users.
+func chatIDOf(peer tg.PeerClass) domain.ChatID {
+	switch peer := peer.(type) {
+	case *tg.PeerChannel:
+		return domain.ChatID(peer.ChannelID)
+	case *tg.PeerChat:
+		return domain.ChatID(peer.ChatID)
+	}
+	return 0
+}
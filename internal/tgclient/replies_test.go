@@ -0,0 +1,71 @@
+package tgclient
+
+import (
+	"testing"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnresolvedReplies(t *testing.T) {
+	msgs := []domain.Message{
+		{ID: 10, ChatID: 100, Text: "album caption", AlbumIDs: []domain.MessageID{10, 11}},
+		{ID: 12, ChatID: 100, ReplyTo: &domain.MessageRef{ID: 11, ChatID: 100}},
+		{ID: 13, ChatID: 100, ReplyTo: &domain.MessageRef{ID: 5, ChatID: 100}},
+		{ID: 14, ChatID: 100, ReplyTo: &domain.MessageRef{ID: 5, ChatID: 100}},
+		// reply to another chat can't be fetched from this one
+		{ID: 15, ChatID: 100, ReplyTo: &domain.MessageRef{ID: 7, ChatID: 200}},
+		// the same id in another chat is a different message
+		{ID: 5, ChatID: 200, Text: "other chat"},
+	}
+
+	texts := knownTexts(msgs, 100)
+	assert.Equal(t, []domain.MessageID{5}, unresolvedReplies(msgs, 100, texts))
+
+	texts[5] = "earlier"
+	embedReplies(msgs, 100, texts)
+	assert.Equal(t, "album caption", msgs[1].ReplyTo.Text)
+	assert.Equal(t, "earlier", msgs[2].ReplyTo.Text)
+	assert.Equal(t, "earlier", msgs[3].ReplyTo.Text)
+	assert.Empty(t, msgs[4].ReplyTo.Text)
+}
+
+func TestToMessage_ReplyAndForward(t *testing.T) {
+	c := testChannel(t, &tg.Channel{ID: 100, Broadcast: true}, 0, domain.Supplier{})
+
+	reply := &tg.MessageReplyHeader{}
+	reply.SetReplyToMsgID(3)
+	reply.SetReplyToPeerID(&tg.PeerChannel{ChannelID: 200})
+
+	fwd := tg.MessageFwdHeader{Date: 1690000000}
+	fwd.SetFromID(&tg.PeerChannel{ChannelID: 300})
+	fwd.SetChannelPost(55)
+	fwd.SetPostAuthor("Dispatcher")
+
+	msg := &tg.Message{ID: 7, PeerID: &tg.PeerChannel{ChannelID: 100}, Date: 1700000000}
+	msg.SetReplyTo(reply)
+	msg.SetFwdFrom(fwd)
+
+	got, err := c.toMessage(msg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &domain.MessageRef{ID: 3, ChatID: 200}, got.ReplyTo)
+	assert.Equal(t, &domain.ForwardOrigin{
+		ChatID:     300,
+		MessageID:  55,
+		Date:       time.Unix(1690000000, 0).UTC(),
+		PostAuthor: "Dispatcher",
+	}, got.ForwardedFrom)
+}
+
+func TestForwardOf_HiddenSender(t *testing.T) {
+	fwd := tg.MessageFwdHeader{Date: 1690000000}
+	fwd.SetFromName("Anonymous")
+
+	assert.Equal(t, &domain.ForwardOrigin{
+		FromName: "Anonymous",
+		Date:     time.Unix(1690000000, 0).UTC(),
+	}, forwardOf(fwd))
+}
@@ -0,0 +1,182 @@
+package tgclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/tg"
+)
+
+// ChannelRef is a parsed TELEGRAM_CHANNELS entry. Exactly one of Username, ID and InviteHash is set.
+type ChannelRef struct {
+	// Username of a public channel
+	Username string
+	// ID of a channel the account has joined, private channels have no username
+	ID int64
+	// InviteHash of a t.me/+ or t.me/joinchat/ link
+	InviteHash string
+	// TopicID limits a forum supergroup to a single topic, set by #<topic id> suffix
+	TopicID int
+}
+
+// channelIDPrefix is prepended to channel ids by Bot API, e.g. -1001234567890
+const channelIDPrefix = "-100"
+
+// ParseChannelRef parses channel username, numeric id, t.me/c/<id> reference or invite link,
+// optionally followed by #<topic id>.
+func ParseChannelRef(s string) (ChannelRef, error) {
+	ref, topic, hasTopic := strings.Cut(strings.TrimSpace(s), "#")
+	parsed, err := parseChatRef(s, ref)
+	if err != nil || !hasTopic {
+		return parsed, err
+	}
+
+	parsed.TopicID, err = strconv.Atoi(topic)
+	if err != nil || parsed.TopicID <= 0 {
+		return ChannelRef{}, fmt.Errorf("invalid topic id: %q", s)
+	}
+	return parsed, nil
+}
+
+func parseChatRef(s string, ref string) (ChannelRef, error) {
+	ref = strings.TrimPrefix(ref, "https://")
+	ref = strings.TrimPrefix(ref, "http://")
+	ref = strings.TrimPrefix(ref, "@")
+
+	if rest, ok := strings.CutPrefix(ref, "t.me/"); ok {
+		rest = strings.TrimSuffix(rest, "/")
+		switch {
+		case strings.HasPrefix(rest, "+"):
+			return inviteRef(s, strings.TrimPrefix(rest, "+"))
+		case strings.HasPrefix(rest, "joinchat/"):
+			return inviteRef(s, strings.TrimPrefix(rest, "joinchat/"))
+		case strings.HasPrefix(rest, "c/"):
+			// t.me/c/<id>/<message id> links to a message of a private channel
+			id, _, _ := strings.Cut(strings.TrimPrefix(rest, "c/"), "/")
+			return idRef(s, id)
+		}
+		ref, _, _ = strings.Cut(rest, "/")
+	}
+
+	if strings.HasPrefix(ref, "-") || (ref != "" && ref[0] >= '0' && ref[0] <= '9') {
+		return idRef(s, strings.TrimPrefix(ref, channelIDPrefix))
+	}
+	if ref == "" {
+		return ChannelRef{}, fmt.Errorf("empty channel reference: %q", s)
+	}
+	return ChannelRef{Username: ref}, nil
+}
+
+func inviteRef(s string, hash string) (ChannelRef, error) {
+	if hash == "" || strings.Contains(hash, "/") {
+		return ChannelRef{}, fmt.Errorf("invalid invite link: %q", s)
+	}
+	return ChannelRef{InviteHash: hash}, nil
+}
+
+func idRef(s string, id string) (ChannelRef, error) {
+	parsed, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || parsed <= 0 {
+		return ChannelRef{}, fmt.Errorf("invalid channel id: %q", s)
+	}
+	return ChannelRef{ID: parsed}, nil
+}
+
+// resolveChat finds the chat, channels come with access hash which is needed for all later calls.
+func resolveChat(ctx context.Context, api *tg.Client, ref ChannelRef) (tg.ChatClass, error) {
+	switch {
+	case ref.ID != 0:
+		return chatFromDialogs(ctx, api, ref.ID)
+	case ref.InviteHash != "":
+		return chatFromInvite(ctx, api, ref.InviteHash)
+	}
+	return channelByUsername(ctx, api, ref.Username)
+}
+
+func channelByUsername(ctx context.Context, api *tg.Client, username string) (tg.ChatClass, error) {
+	resolved, err := api.ContactsResolveUsername(ctx,
+		&tg.ContactsResolveUsernameRequest{
+			Username: username,
+		})
+	if err != nil {
+		err := fmt.Errorf("failed to resolve channel username: %w", err)
+		return nil, err
+	}
+
+	if len(resolved.Chats) != 1 {
+		return nil, fmt.Errorf("channels found: %v", len(resolved.Chats))
+	}
+	return asChat(resolved.Chats[0], username)
+}
+
+// chatFromDialogs looks the chat up in the account's dialogs, as a chat without
+// username can't be resolved and access hash of a channel is only known to its members.
+func chatFromDialogs(ctx context.Context, api *tg.Client, id int64) (tg.ChatClass, error) {
+	iter := dialogs.NewQueryBuilder(api).GetDialogs().BatchSize(100).Iter()
+	for iter.Next(ctx) {
+		entities := iter.Value().Entities
+		if channel, ok := entities.Channel(id); ok {
+			return channel, nil
+		}
+		if chat, ok := entities.Chat(id); ok {
+			return asChat(chat, strconv.FormatInt(id, 10))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate dialogs: %w", err)
+	}
+	return nil, fmt.Errorf("chat %d not found in dialogs, the account has to join it", id)
+}
+
+// chatFromInvite returns the chat of the invite link, joining it if the account is not a member yet.
+func chatFromInvite(ctx context.Context, api *tg.Client, hash string) (tg.ChatClass, error) {
+	invite, err := api.MessagesCheckChatInvite(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invite link: %w", err)
+	}
+	if already, ok := invite.(*tg.ChatInviteAlready); ok {
+		return asChat(already.Chat, "+"+hash)
+	}
+
+	joined, err := api.MessagesImportChatInvite(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join by invite link: %w", err)
+	}
+
+	var chats []tg.ChatClass
+	switch u := joined.(type) {
+	case *tg.Updates:
+		chats = u.Chats
+	case *tg.UpdatesCombined:
+		chats = u.Chats
+	}
+	for _, chat := range chats {
+		if chat, err := asChat(chat, "+"+hash); err == nil {
+			return chat, nil
+		}
+	}
+	return nil, errors.New("joined by invite link, but no chat was returned")
+}
+
+// asChat accepts channels, supergroups and basic groups the account can read.
+func asChat(chat tg.ChatClass, name string) (tg.ChatClass, error) {
+	switch chat := chat.(type) {
+	case *tg.Channel:
+		return chat, nil
+	case *tg.Chat:
+		if migratedTo, ok := chat.GetMigratedTo(); ok {
+			if channel, ok := migratedTo.(*tg.InputChannel); ok {
+				return nil, fmt.Errorf("group %v was upgraded to supergroup %d, configure it instead", name, channel.ChannelID)
+			}
+		}
+		if chat.Deactivated {
+			return nil, fmt.Errorf("group %v is deactivated", name)
+		}
+		return chat, nil
+	}
+	return nil, fmt.Errorf("is not a channel or group: %v", name)
+}
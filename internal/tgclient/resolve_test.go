@@ -0,0 +1,43 @@
+package tgclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChannelRef(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ChannelRef
+	}{
+		{in: "vodokanalpmrcom", want: ChannelRef{Username: "vodokanalpmrcom"}},
+		{in: "@vodokanalpmrcom", want: ChannelRef{Username: "vodokanalpmrcom"}},
+		{in: "https://t.me/vodokanalpmrcom", want: ChannelRef{Username: "vodokanalpmrcom"}},
+		{in: "t.me/vodokanalpmrcom/125", want: ChannelRef{Username: "vodokanalpmrcom"}},
+		{in: "1234567890", want: ChannelRef{ID: 1234567890}},
+		{in: "-1001234567890", want: ChannelRef{ID: 1234567890}},
+		{in: "https://t.me/c/1234567890", want: ChannelRef{ID: 1234567890}},
+		{in: "t.me/c/1234567890/42", want: ChannelRef{ID: 1234567890}},
+		{in: "https://t.me/+AbCdEf_123", want: ChannelRef{InviteHash: "AbCdEf_123"}},
+		{in: "t.me/joinchat/AbCdEf_123", want: ChannelRef{InviteHash: "AbCdEf_123"}},
+		{in: "citygroup#12", want: ChannelRef{Username: "citygroup", TopicID: 12}},
+		{in: "-1001234567890#3", want: ChannelRef{ID: 1234567890, TopicID: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseChannelRef(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseChannelRef_Invalid(t *testing.T) {
+	for _, in := range []string{"", "@", "t.me/+", "t.me/c/abc", "-42x", "t.me/c/-5", "citygroup#", "citygroup#x"} {
+		_, err := ParseChannelRef(in)
+		assert.Error(t, err, in)
+	}
+}
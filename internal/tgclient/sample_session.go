@@ -2,25 +2,28 @@ package tgclient
 
 import (
 	"context"
+	"fmt"
 	"github.com/fatih/color"
 	"tg-bridge/internal/tgsession"
 )
 
-// GenerateSampleSession generates sample session JSON and stores in provided session storage
-func GenerateSampleSession(ctx context.Context, session *tgsession.MemorySessionStorage) error {
+// GenerateSampleSession generates sample session JSON and stores in provided session storage,
+// the session is marked for test DCs if testDC is set
+func GenerateSampleSession(ctx context.Context, session *tgsession.MemorySessionStorage, testDC bool) error {
 	_, _ = color.New(color.FgBlack, color.BgHiYellow).Print(" Warning! ")
 	color.Yellow(" Generating sample session...")
 	// sample session with minimally required session fields
-	sessionJson := `{
+	sessionJson := fmt.Sprintf(`{
   "Version": 1,
   "Data": {
+	"Config": {"TestMode": %t},
 	"DC": 0,
 	"Addr": "",
 	"AuthKey": "QVVUSF9LRVlfSEVSRQo=",
 	"AuthKeyID": "QVVUSF9LRVlfSURfSEVSRQo=",
 	"Salt": 12345
   }
-}`
+}`, testDC)
 	err := session.StoreSession(ctx, []byte(sessionJson))
 	if err != nil {
 		color.Red("failed to save sample session: %s", err)
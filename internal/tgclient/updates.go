@@ -0,0 +1,361 @@
+package tgclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+)
+
+// Updates turns Telegram updates of tracked channels and groups into domain messages in real time.
+// Handler must be passed to the client as ClientOptions.UpdateHandler before the client is started.
+type Updates struct {
+	manager   *updates.Manager
+	messages  chan domain.Message
+	edits     chan domain.Message
+	deletions chan domain.MessageDeletion
+	events    chan domain.ServiceEvent
+	pins      chan domain.ChatID
+	catchUp   chan int64
+
+	mu       sync.RWMutex
+	channels map[int64]*Channel
+
+	// album parts arrive as separate updates, they are merged while parts keep coming within albumWait
+	albumWait time.Duration
+	albumMu   sync.Mutex
+	albums    map[domain.ChatID]*pendingAlbum
+	stopped   chan struct{}
+}
+
+type pendingAlbum struct {
+	message domain.Message
+	timer   *time.Timer
+}
+
+func NewUpdates(buffer int, albumWait time.Duration) *Updates {
+	return newUpdates(&Updates{
+		messages:  make(chan domain.Message, buffer),
+		edits:     make(chan domain.Message, buffer),
+		deletions: make(chan domain.MessageDeletion, buffer),
+		events:    make(chan domain.ServiceEvent, buffer),
+		pins:      make(chan domain.ChatID, buffer),
+		catchUp:   make(chan int64, 1),
+		albumWait: albumWait,
+	})
+}
+
+// ForAccount returns updates of another account delivered to the same streams. Each account
+// receives updates of the channels tracked on it and needs a handler of its own.
+func (u *Updates) ForAccount() *Updates {
+	return newUpdates(&Updates{
+		messages:  u.messages,
+		edits:     u.edits,
+		deletions: u.deletions,
+		events:    u.events,
+		pins:      u.pins,
+		catchUp:   u.catchUp,
+		albumWait: u.albumWait,
+	})
+}
+
+func newUpdates(u *Updates) *Updates {
+	u.channels = make(map[int64]*Channel)
+	u.albums = make(map[domain.ChatID]*pendingAlbum)
+	u.stopped = make(chan struct{})
+
+	dispatcher := tg.NewUpdateDispatcher()
+	dispatcher.OnNewChannelMessage(u.onNewChannelMessage)
+	dispatcher.OnEditChannelMessage(u.onEditChannelMessage)
+	dispatcher.OnDeleteChannelMessages(u.onDeleteChannelMessages)
+	// basic groups get the same updates as private chats
+	dispatcher.OnNewMessage(u.onNewMessage)
+	dispatcher.OnEditMessage(u.onEditMessage)
+	dispatcher.OnDeleteMessages(u.onDeleteMessages)
+	dispatcher.OnPinnedChannelMessages(u.onPinnedChannelMessages)
+	dispatcher.OnPinnedMessages(u.onPinnedMessages)
+
+	u.manager = updates.New(updates.Config{
+		Handler: dispatcher,
+		// Manager could not recover the gap by itself, history polling has to catch up
+		OnChannelTooLong: u.requestCatchUp,
+	})
+	return u
+}
+
+// Handler returns update handler to be registered in telegram.Options.
+func (u *Updates) Handler() telegram.UpdateHandler {
+	return u.manager
+}
+
+// Track starts delivering new messages of the channel.
+func (u *Updates) Track(channel *Channel) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.channels[channel.Id()] = channel
+}
+
+// Untrack stops delivering messages of the channel, e.g. when it moved to another account.
+func (u *Updates) Untrack(channel *Channel) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.channels[channel.Id()] == channel {
+		delete(u.channels, channel.Id())
+	}
+}
+
+// Messages returns a stream of new messages of tracked channels.
+func (u *Updates) Messages() <-chan domain.Message {
+	return u.messages
+}
+
+// Edits returns a stream of edited messages of tracked channels.
+func (u *Updates) Edits() <-chan domain.Message {
+	return u.edits
+}
+
+// Deletions returns a stream of deleted messages of tracked channels.
+func (u *Updates) Deletions() <-chan domain.MessageDeletion {
+	return u.deletions
+}
+
+// Events returns a stream of service events of tracked channels.
+func (u *Updates) Events() <-chan domain.ServiceEvent {
+	return u.events
+}
+
+// Pins returns a stream of tracked chats whose pinned messages changed.
+func (u *Updates) Pins() <-chan domain.ChatID {
+	return u.pins
+}
+
+// CatchUp returns a stream of channel ids whose updates were lost and have to be fetched from history.
+func (u *Updates) CatchUp() <-chan int64 {
+	return u.catchUp
+}
+
+// Run receives updates until ctx is done. It must be called inside client.Run.
+func (u *Updates) Run(ctx context.Context, client *telegram.Client) error {
+	defer close(u.stopped)
+
+	self, err := client.Self(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get self user: %w", err)
+	}
+	return u.manager.Run(ctx, client.API(), self.ID, updates.AuthOptions{
+		OnStart: func(ctx context.Context) {
+			log.Println("📡 Listening for Telegram updates")
+		},
+	})
+}
+
+func (u *Updates) onNewChannelMessage(ctx context.Context, e tg.Entities, update *tg.UpdateNewChannelMessage) error {
+	return u.onMessage(ctx, e, update.Message)
+}
+
+func (u *Updates) onNewMessage(ctx context.Context, e tg.Entities, update *tg.UpdateNewMessage) error {
+	return u.onMessage(ctx, e, update.Message)
+}
+
+func (u *Updates) onMessage(ctx context.Context, e tg.Entities, obj tg.MessageClass) error {
+	if service, ok := obj.(*tg.MessageService); ok {
+		if channel, ok := u.trackedOf(service.PeerID); ok && channel.accepts(service.ReplyTo) {
+			return send(ctx, u.events, channel.toEvent(service, e.Users))
+		}
+		return nil
+	}
+
+	m, ok, err := u.convert(obj, e.Users)
+	if err != nil || !ok {
+		return err
+	}
+
+	u.albumMu.Lock()
+	defer u.albumMu.Unlock()
+
+	pending := u.albums[m.ChatID]
+	if pending != nil && m.GroupedID != 0 && pending.message.GroupedID == m.GroupedID {
+		mergeInto(&pending.message, m)
+		pending.timer.Reset(u.albumWait)
+		return nil
+	}
+
+	// a message out of the pending album means the album is complete
+	if pending != nil {
+		pending.timer.Stop()
+		delete(u.albums, m.ChatID)
+		if err := send(ctx, u.messages, pending.message); err != nil {
+			return err
+		}
+	}
+
+	if m.GroupedID != 0 {
+		chatID, groupedID := m.ChatID, m.GroupedID
+		u.albums[chatID] = &pendingAlbum{
+			message: m,
+			timer: time.AfterFunc(u.albumWait, func() {
+				u.flushAlbum(chatID, groupedID)
+			}),
+		}
+		return nil
+	}
+	return send(ctx, u.messages, m)
+}
+
+// flushAlbum delivers pending album when no more parts arrived within albumWait.
+func (u *Updates) flushAlbum(chatID domain.ChatID, groupedID int64) {
+	u.albumMu.Lock()
+	defer u.albumMu.Unlock()
+
+	pending := u.albums[chatID]
+	if pending == nil || pending.message.GroupedID != groupedID {
+		// already delivered by the next message
+		return
+	}
+	delete(u.albums, chatID)
+
+	select {
+	case u.messages <- pending.message:
+	case <-u.stopped:
+	}
+}
+
+func (u *Updates) onEditChannelMessage(ctx context.Context, e tg.Entities, update *tg.UpdateEditChannelMessage) error {
+	return u.onEdit(ctx, e, update.Message)
+}
+
+func (u *Updates) onEditMessage(ctx context.Context, e tg.Entities, update *tg.UpdateEditMessage) error {
+	return u.onEdit(ctx, e, update.Message)
+}
+
+func (u *Updates) onEdit(ctx context.Context, e tg.Entities, obj tg.MessageClass) error {
+	m, ok, err := u.convert(obj, e.Users)
+	if err != nil || !ok {
+		return err
+	}
+	return send(ctx, u.edits, m)
+}
+
+func (u *Updates) onDeleteChannelMessages(ctx context.Context, _ tg.Entities, update *tg.UpdateDeleteChannelMessages) error {
+	u.mu.RLock()
+	_, tracked := u.channels[update.ChannelID]
+	u.mu.RUnlock()
+	if !tracked {
+		return nil
+	}
+
+	return u.sendDeletions(ctx, update.ChannelID, update.Messages)
+}
+
+// onDeleteMessages handles deletions in basic groups. Telegram does not tell the chat, as message ids
+// are unique for all private chats and basic groups of the account, so deletion is sent for each tracked
+// basic group and only the one which published the message reports it.
+func (u *Updates) onDeleteMessages(ctx context.Context, _ tg.Entities, update *tg.UpdateDeleteMessages) error {
+	u.mu.RLock()
+	var groups []int64
+	for id, channel := range u.channels {
+		if channel.channel == nil {
+			groups = append(groups, id)
+		}
+	}
+	u.mu.RUnlock()
+
+	for _, chatID := range groups {
+		if err := u.sendDeletions(ctx, chatID, update.Messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *Updates) sendDeletions(ctx context.Context, chatID int64, ids []int) error {
+	now := time.Now().UTC()
+	for _, id := range ids {
+		deletion := domain.MessageDeletion{
+			ID:         domain.MessageID(id),
+			ChatID:     domain.ChatID(chatID),
+			DetectedAt: now,
+		}
+		if err := send(ctx, u.deletions, deletion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *Updates) onPinnedChannelMessages(ctx context.Context, _ tg.Entities, update *tg.UpdatePinnedChannelMessages) error {
+	return u.onPinned(ctx, &tg.PeerChannel{ChannelID: update.ChannelID})
+}
+
+func (u *Updates) onPinnedMessages(ctx context.Context, _ tg.Entities, update *tg.UpdatePinnedMessages) error {
+	return u.onPinned(ctx, update.Peer)
+}
+
+// onPinned reports the chat only, several messages may be pinned and the latest one has to be fetched anyway.
+func (u *Updates) onPinned(ctx context.Context, peer tg.PeerClass) error {
+	channel, tracked := u.trackedOf(peer)
+	if !tracked {
+		return nil
+	}
+	return send(ctx, u.pins, domain.ChatID(channel.Id()))
+}
+
+// convert converts message of a tracked channel or group, other messages are not ok.
+func (u *Updates) convert(obj tg.MessageClass, users map[int64]*tg.User) (domain.Message, bool, error) {
+	msg, ok := obj.(*tg.Message)
+	if !ok {
+		return domain.Message{}, false, nil
+	}
+
+	channel, tracked := u.trackedOf(msg.PeerID)
+	if !tracked || !channel.accepts(msg.ReplyTo) {
+		return domain.Message{}, false, nil
+	}
+
+	m, err := channel.toMessage(msg, users)
+	if err != nil {
+		return domain.Message{}, false, err
+	}
+	return m, true, nil
+}
+
+// trackedOf returns tracked channel or group of the peer.
+func (u *Updates) trackedOf(peer tg.PeerClass) (*Channel, bool) {
+	var chatID int64
+	switch peer := peer.(type) {
+	case *tg.PeerChannel:
+		chatID = peer.ChannelID
+	case *tg.PeerChat:
+		chatID = peer.ChatID
+	default:
+		return nil, false
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	channel, tracked := u.channels[chatID]
+	return channel, tracked
+}
+
+func send[T any](ctx context.Context, out chan<- T, v T) error {
+	select {
+	case out <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (u *Updates) requestCatchUp(channelID int64) {
+	select {
+	case u.catchUp <- channelID:
+	default:
+		// catch up is already pending, it polls all channels anyway
+	}
+}
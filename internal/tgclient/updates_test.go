@@ -0,0 +1,261 @@
+package tgclient
+
+import (
+	"context"
+	"testing"
+	"tg-bridge/internal/domain"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdates_DeliversTrackedChannelMessages(t *testing.T) {
+	ctx := context.Background()
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{Type: "water"}))
+
+	newMessage := func(channelID int64, id int) *tg.UpdateNewChannelMessage {
+		return &tg.UpdateNewChannelMessage{
+			Message: &tg.Message{
+				ID:      id,
+				PeerID:  &tg.PeerChannel{ChannelID: channelID},
+				Message: "planned outage",
+				Date:    1700000000,
+			},
+		}
+	}
+
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, newMessage(200, 1)))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, newMessage(100, 2)))
+
+	require.Len(t, u.Messages(), 1, "only tracked channel messages should be delivered")
+	m := <-u.Messages()
+	assert.Equal(t, domain.MessageID(2), m.ID)
+	assert.Equal(t, domain.ChatID(100), m.ChatID)
+	assert.Equal(t, "planned outage", m.Text)
+	assert.Equal(t, "water", m.Context["supplier"])
+}
+
+func testChannel(t *testing.T, chat tg.ChatClass, topicID int, supplier domain.Supplier) *Channel {
+	t.Helper()
+	c, err := newChannel(nil, chat, topicID, supplier)
+	require.NoError(t, err)
+	return c
+}
+
+func TestUpdates_DeliversBasicGroupMessages(t *testing.T) {
+	ctx := context.Background()
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Chat{ID: 300}, 0, domain.Supplier{Type: "gas"}))
+
+	msg := &tg.Message{
+		ID:      9,
+		PeerID:  &tg.PeerChat{ChatID: 300},
+		FromID:  &tg.PeerUser{UserID: 42},
+		Message: "no gas on Lenin st.",
+		Date:    1700000000,
+	}
+	entities := tg.Entities{Users: map[int64]*tg.User{42: {ID: 42, FirstName: "Ivan", LastName: "Petrov"}}}
+	require.NoError(t, u.onNewMessage(ctx, entities, &tg.UpdateNewMessage{Message: msg}))
+
+	require.Len(t, u.Messages(), 1)
+	m := <-u.Messages()
+	assert.Equal(t, domain.ChatID(300), m.ChatID)
+	assert.Equal(t, domain.User{ID: 42, Name: "Ivan Petrov"}, m.From)
+
+	// deletions of basic groups carry no chat, only tracked groups get them
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+	require.NoError(t, u.onDeleteMessages(ctx, tg.Entities{}, &tg.UpdateDeleteMessages{Messages: []int{9}}))
+	require.Len(t, u.Deletions(), 1)
+	assert.Equal(t, domain.ChatID(300), (<-u.Deletions()).ChatID)
+}
+
+func TestUpdates_FiltersForumTopic(t *testing.T) {
+	ctx := context.Background()
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100, Forum: true}, 5, domain.Supplier{}))
+
+	inTopic := func(id int, header *tg.MessageReplyHeader) *tg.UpdateNewChannelMessage {
+		msg := &tg.Message{ID: id, PeerID: &tg.PeerChannel{ChannelID: 100}, Date: 1700000000}
+		if header != nil {
+			msg.SetReplyTo(header)
+		}
+		return &tg.UpdateNewChannelMessage{Message: msg}
+	}
+	reply := &tg.MessageReplyHeader{ForumTopic: true}
+	reply.SetReplyToMsgID(7)
+	reply.SetReplyToTopID(5)
+
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, inTopic(6, &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 5})))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, inTopic(8, &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 3})))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, inTopic(9, nil)))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, inTopic(10, reply)))
+
+	require.Len(t, u.Messages(), 2, "only messages of topic 5 should be delivered")
+	first, second := <-u.Messages(), <-u.Messages()
+	assert.Equal(t, 5, first.TopicID)
+	assert.Nil(t, first.ReplyTo, "post to a topic is not a reply")
+	assert.Equal(t, domain.MessageID(10), second.ID)
+	require.NotNil(t, second.ReplyTo)
+	assert.Equal(t, domain.MessageID(7), second.ReplyTo.ID)
+
+	pinned := func(id int, topicID int) *tg.UpdateNewChannelMessage {
+		msg := serviceMessage(id, &tg.MessageActionPinMessage{})
+		header := &tg.MessageReplyHeader{ForumTopic: true}
+		header.SetReplyToMsgID(6)
+		header.SetReplyToTopID(topicID)
+		msg.SetReplyTo(header)
+		return &tg.UpdateNewChannelMessage{Message: msg}
+	}
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, pinned(11, 3)))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, pinned(12, 5)))
+	require.Len(t, u.Events(), 1, "only service messages of topic 5 should be delivered")
+	assert.Equal(t, domain.MessageID(12), (<-u.Events()).ID)
+}
+
+func albumPart(id int, groupedID int64, text string) *tg.UpdateNewChannelMessage {
+	msg := &tg.Message{
+		ID:      id,
+		PeerID:  &tg.PeerChannel{ChannelID: 100},
+		Message: text,
+		Date:    1700000000,
+	}
+	msg.SetMedia(&tg.MessageMediaPhoto{Photo: &tg.Photo{ID: int64(id)}})
+	if groupedID != 0 {
+		msg.SetGroupedID(groupedID)
+	}
+	return &tg.UpdateNewChannelMessage{Message: msg}
+}
+
+func TestUpdates_MergesAlbums(t *testing.T) {
+	ctx := context.Background()
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, albumPart(1, 77, "schedule")))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, albumPart(2, 77, "")))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, albumPart(3, 77, "")))
+	require.Len(t, u.Messages(), 0, "album is held back while parts keep coming")
+
+	// next album completes the first one
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, albumPart(4, 88, "")))
+	require.Len(t, u.Messages(), 1)
+	album := <-u.Messages()
+	assert.Equal(t, domain.MessageID(1), album.ID)
+	assert.Equal(t, []domain.MessageID{1, 2, 3}, album.AlbumIDs)
+	assert.Equal(t, "schedule", album.Text)
+	assert.Len(t, album.Attachments, 3)
+
+	// single message completes the second one and is delivered after it
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, albumPart(5, 0, "text")))
+	require.Len(t, u.Messages(), 2)
+	assert.Equal(t, []domain.MessageID{4}, (<-u.Messages()).AlbumIDs)
+	assert.Equal(t, domain.MessageID(5), (<-u.Messages()).ID)
+}
+
+func TestUpdates_FlushesAlbumAfterWait(t *testing.T) {
+	u := NewUpdates(10, 10*time.Millisecond)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+
+	require.NoError(t, u.onNewChannelMessage(context.Background(), tg.Entities{}, albumPart(1, 77, "schedule")))
+	require.NoError(t, u.onNewChannelMessage(context.Background(), tg.Entities{}, albumPart(2, 77, "")))
+
+	select {
+	case album := <-u.Messages():
+		assert.Equal(t, []domain.MessageID{1, 2}, album.AlbumIDs)
+	case <-time.After(time.Second):
+		t.Fatal("album was not delivered after wait")
+	}
+}
+
+func TestUpdates_DeliversEdits(t *testing.T) {
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+
+	edit := &tg.UpdateEditChannelMessage{
+		Message: &tg.Message{
+			ID:      5,
+			PeerID:  &tg.PeerChannel{ChannelID: 100},
+			Message: "outage moved to 14:00",
+			Date:    1700000000,
+		},
+	}
+	edit.Message.(*tg.Message).SetEditDate(1700000600)
+
+	require.NoError(t, u.onEditChannelMessage(context.Background(), tg.Entities{}, edit))
+
+	require.Len(t, u.Messages(), 0)
+	require.Len(t, u.Edits(), 1)
+	m := <-u.Edits()
+	require.NotNil(t, m.EditDate)
+	assert.Equal(t, int64(1700000600), m.EditDate.Unix())
+	assert.Equal(t, "outage moved to 14:00", m.Text)
+}
+
+func TestUpdates_DeliversDeletions(t *testing.T) {
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+
+	ctx := context.Background()
+	require.NoError(t, u.onDeleteChannelMessages(ctx, tg.Entities{}, &tg.UpdateDeleteChannelMessages{
+		ChannelID: 200,
+		Messages:  []int{1},
+	}))
+	require.NoError(t, u.onDeleteChannelMessages(ctx, tg.Entities{}, &tg.UpdateDeleteChannelMessages{
+		ChannelID: 100,
+		Messages:  []int{7, 8},
+	}))
+
+	require.Len(t, u.Deletions(), 2)
+	first, second := <-u.Deletions(), <-u.Deletions()
+	assert.Equal(t, domain.MessageDeletion{ID: 7, ChatID: 100, DetectedAt: first.DetectedAt}, first)
+	assert.Equal(t, domain.MessageID(8), second.ID)
+	assert.False(t, first.DetectedAt.IsZero())
+}
+
+func TestUpdates_DeliversServiceEvents(t *testing.T) {
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+
+	ctx := context.Background()
+	other := serviceMessage(3, &tg.MessageActionChatEditTitle{Title: "Other"})
+	other.PeerID = &tg.PeerChannel{ChannelID: 200}
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, &tg.UpdateNewChannelMessage{Message: other}))
+	require.NoError(t, u.onNewChannelMessage(ctx, tg.Entities{}, &tg.UpdateNewChannelMessage{
+		Message: serviceMessage(4, &tg.MessageActionChatEditTitle{Title: "Vodokanal"}),
+	}))
+
+	require.Len(t, u.Messages(), 0)
+	require.Len(t, u.Events(), 1)
+	event := <-u.Events()
+	assert.Equal(t, domain.EventTitleChanged, event.Kind)
+	assert.Equal(t, "Vodokanal", event.Title)
+}
+
+func TestUpdates_DeliversPins(t *testing.T) {
+	u := NewUpdates(10, time.Minute)
+	u.Track(testChannel(t, &tg.Channel{ID: 100}, 0, domain.Supplier{}))
+	u.Track(testChannel(t, &tg.Chat{ID: 300}, 0, domain.Supplier{}))
+
+	ctx := context.Background()
+	require.NoError(t, u.onPinnedChannelMessages(ctx, tg.Entities{}, &tg.UpdatePinnedChannelMessages{ChannelID: 200, Messages: []int{1}}))
+	require.NoError(t, u.onPinnedChannelMessages(ctx, tg.Entities{}, &tg.UpdatePinnedChannelMessages{ChannelID: 100, Messages: []int{5}}))
+	require.NoError(t, u.onPinnedMessages(ctx, tg.Entities{}, &tg.UpdatePinnedMessages{Peer: &tg.PeerChat{ChatID: 300}, Messages: []int{9}}))
+	require.NoError(t, u.onPinnedMessages(ctx, tg.Entities{}, &tg.UpdatePinnedMessages{Peer: &tg.PeerUser{UserID: 42}, Messages: []int{9}}))
+
+	require.Len(t, u.Pins(), 2)
+	assert.Equal(t, domain.ChatID(100), <-u.Pins())
+	assert.Equal(t, domain.ChatID(300), <-u.Pins())
+}
+
+func TestUpdates_CatchUpIsNotBlocking(t *testing.T) {
+	u := NewUpdates(1, time.Minute)
+
+	u.requestCatchUp(1)
+	u.requestCatchUp(2)
+
+	assert.Equal(t, int64(1), <-u.CatchUp())
+	assert.Len(t, u.CatchUp(), 0)
+}
@@ -0,0 +1,363 @@
+// Package tgfake is an in-process fake of the Telegram API for offline tests. Invoker is passed where
+// a *telegram.Client is used as tg.Invoker and serves scripted responses, results are encoded and decoded
+// as on the wire, so flags and boxed types behave as in responses of Telegram.
+package tgfake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"tg-bridge/internal/tgrecord"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Handler returns the result of the request or an error, usually *tgerr.Error.
+type Handler func(ctx context.Context, req bin.Encoder) (bin.Encoder, error)
+
+type typeIDer interface {
+	TypeID() uint32
+}
+
+// Invoker serves API calls by handlers registered for the request types. Channels added with AddChannel
+// are served by built-in handlers of username resolution and history, any of them may be replaced by Handle.
+type Invoker struct {
+	mu       sync.Mutex
+	handlers map[uint32]Handler
+	failures map[uint32][]error
+	calls    map[uint32]int
+	channels []*channel
+}
+
+type channel struct {
+	chat *tg.Channel
+	// messages in ascending id order
+	messages []tg.MessageClass
+	users    []tg.UserClass
+}
+
+func New() *Invoker {
+	f := &Invoker{
+		handlers: make(map[uint32]Handler),
+		failures: make(map[uint32][]error),
+		calls:    make(map[uint32]int),
+	}
+	f.handlers[tg.ContactsResolveUsernameRequestTypeID] = f.resolveUsername
+	f.handlers[tg.ChannelsGetChannelsRequestTypeID] = f.getChannels
+	f.handlers[tg.MessagesGetHistoryRequestTypeID] = f.getHistory
+	f.handlers[tg.ChannelsGetMessagesRequestTypeID] = f.getMessages
+	return f
+}
+
+// Invoke implements tg.Invoker.
+func (f *Invoker) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	req, ok := input.(typeIDer)
+	if !ok {
+		return fmt.Errorf("tgfake: request %T has no type id", input)
+	}
+
+	f.mu.Lock()
+	id := req.TypeID()
+	f.calls[id]++
+	if errs := f.failures[id]; len(errs) > 0 {
+		f.failures[id] = errs[1:]
+		f.mu.Unlock()
+		return errs[0]
+	}
+	h, ok := f.handlers[id]
+	f.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("tgfake: no handler for %T", input)
+	}
+
+	res, err := h(ctx, input)
+	if err != nil {
+		return err
+	}
+	var buf bin.Buffer
+	if err := res.Encode(&buf); err != nil {
+		return fmt.Errorf("tgfake: encode %T: %w", res, err)
+	}
+	return output.Decode(&buf)
+}
+
+// Handle serves requests of the same type as req by h, replacing the built-in handler if there is one.
+func (f *Invoker) Handle(req bin.Encoder, h Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[typeID(req)] = h
+}
+
+// Fail makes the next calls of the same type as req fail with errs in order, then they are served again.
+func (f *Invoker) Fail(req bin.Encoder, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := typeID(req)
+	f.failures[id] = append(f.failures[id], errs...)
+}
+
+// Calls returns the number of calls of the same type as req, including failed ones.
+func (f *Invoker) Calls(req bin.Encoder) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[typeID(req)]
+}
+
+// AddChannel makes the channel resolvable by its username and serves its history. Messages
+// are posted to the channel in any order, authors of messages are given as users. Required
+// fields left empty, such as the photo of the channel or peers of messages, are filled in.
+func (f *Invoker) AddChannel(chat *tg.Channel, users []tg.UserClass, msgs ...tg.MessageClass) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := *chat
+	if c.Photo == nil {
+		c.Photo = &tg.ChatPhotoEmpty{}
+	}
+	f.channels = append(f.channels, &channel{chat: &c, users: users})
+	f.post(chat.ID, msgs)
+}
+
+// Post adds messages to history of the added channel, e.g. between polls.
+func (f *Invoker) Post(channelID int64, msgs ...tg.MessageClass) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.post(channelID, msgs)
+}
+
+// Delete removes messages from history of the added channel.
+func (f *Invoker) Delete(channelID int64, ids ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ch := f.channel(channelID); ch != nil {
+		ch.messages = slices.DeleteFunc(ch.messages, func(m tg.MessageClass) bool {
+			return slices.Contains(ids, m.GetID())
+		})
+	}
+}
+
+func (f *Invoker) post(channelID int64, msgs []tg.MessageClass) {
+	ch := f.channel(channelID)
+	if ch == nil {
+		panic(fmt.Sprintf("tgfake: channel %d is not added", channelID))
+	}
+	peer := &tg.PeerChannel{ChannelID: channelID}
+	for _, m := range msgs {
+		switch m := m.(type) {
+		case *tg.Message:
+			copied := *m
+			if copied.PeerID == nil {
+				copied.PeerID = peer
+			}
+			ch.messages = append(ch.messages, &copied)
+		case *tg.MessageService:
+			copied := *m
+			if copied.PeerID == nil {
+				copied.PeerID = peer
+			}
+			ch.messages = append(ch.messages, &copied)
+		default:
+			ch.messages = append(ch.messages, m)
+		}
+	}
+	slices.SortFunc(ch.messages, func(a, b tg.MessageClass) int { return a.GetID() - b.GetID() })
+}
+
+func (f *Invoker) channel(id int64) *channel {
+	for _, ch := range f.channels {
+		if ch.chat.ID == id {
+			return ch
+		}
+	}
+	return nil
+}
+
+// known returns the added channel, calls with channels the account doesn't know fail as in Telegram.
+func (f *Invoker) known(id int64) (*channel, error) {
+	if ch := f.channel(id); ch != nil {
+		return ch, nil
+	}
+	return nil, tgerr.New(400, "CHANNEL_INVALID")
+}
+
+func (f *Invoker) inputChannel(input tg.InputChannelClass) (*channel, error) {
+	c, ok := input.(*tg.InputChannel)
+	if !ok {
+		return nil, tgerr.New(400, "CHANNEL_INVALID")
+	}
+	return f.known(c.ChannelID)
+}
+
+func (f *Invoker) resolveUsername(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+	username := req.(*tg.ContactsResolveUsernameRequest).Username
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if strings.EqualFold(ch.chat.Username, username) {
+			return &tg.ContactsResolvedPeer{
+				Peer:  &tg.PeerChannel{ChannelID: ch.chat.ID},
+				Chats: []tg.ChatClass{ch.chat},
+				Users: []tg.UserClass{},
+			}, nil
+		}
+	}
+	return nil, tgerr.New(400, "USERNAME_NOT_OCCUPIED")
+}
+
+func (f *Invoker) getChannels(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := &tg.MessagesChats{}
+	for _, input := range req.(*tg.ChannelsGetChannelsRequest).ID {
+		ch, err := f.inputChannel(input)
+		if err != nil {
+			return nil, err
+		}
+		res.Chats = append(res.Chats, ch.chat)
+	}
+	return res, nil
+}
+
+// getHistory returns messages newer than min id, newest first. The page starts after the messages
+// as new as offset id or date, shifted by add offset: negative one returns messages from offset id on.
+func (f *Invoker) getHistory(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+	r := req.(*tg.MessagesGetHistoryRequest)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	peer, ok := r.Peer.(*tg.InputPeerChannel)
+	if !ok {
+		return nil, tgerr.New(400, "PEER_ID_INVALID")
+	}
+	ch, err := f.known(peer.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []tg.MessageClass
+	for _, m := range slices.Backward(ch.messages) {
+		if r.MaxID != 0 && m.GetID() >= r.MaxID || m.GetID() <= r.MinID {
+			continue
+		}
+		history = append(history, m)
+	}
+	start := slices.IndexFunc(history, func(m tg.MessageClass) bool {
+		return (r.OffsetID == 0 || m.GetID() < r.OffsetID) && (r.OffsetDate == 0 || dateOf(m) < r.OffsetDate)
+	})
+	if start < 0 {
+		start = len(history)
+	}
+	start = max(start+r.AddOffset, 0)
+	end := min(start+r.Limit, len(history))
+	if start >= end {
+		return ch.messagesOf(nil), nil
+	}
+	return ch.messagesOf(history[start:end]), nil
+}
+
+// getMessages returns messages by ids, deleted ones as MessageEmpty.
+func (f *Invoker) getMessages(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+	r := req.(*tg.ChannelsGetMessagesRequest)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, err := f.inputChannel(r.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]tg.MessageClass, 0, len(r.ID))
+	for _, input := range r.ID {
+		id, ok := input.(*tg.InputMessageID)
+		if !ok {
+			return nil, fmt.Errorf("tgfake: unsupported message reference %T", input)
+		}
+		i := slices.IndexFunc(ch.messages, func(m tg.MessageClass) bool { return m.GetID() == id.ID })
+		if i < 0 {
+			result = append(result, &tg.MessageEmpty{ID: id.ID})
+			continue
+		}
+		result = append(result, ch.messages[i])
+	}
+	return ch.messagesOf(result), nil
+}
+
+func (ch *channel) messagesOf(msgs []tg.MessageClass) *tg.MessagesChannelMessages {
+	if msgs == nil {
+		msgs = []tg.MessageClass{}
+	}
+	users := ch.users
+	if users == nil {
+		users = []tg.UserClass{}
+	}
+	return &tg.MessagesChannelMessages{
+		Count:    len(ch.messages),
+		Messages: msgs,
+		Chats:    []tg.ChatClass{ch.chat},
+		Users:    users,
+		Topics:   []tg.ForumTopicClass{},
+	}
+}
+
+// Replay serves calls recorded by tgrecord.Recorder: a request equal to a recorded one gets its recorded
+// response, the latest one if the request was recorded several times. Replayed methods replace their handlers.
+func (f *Invoker) Replay(records ...tgrecord.Record) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	byMethod := make(map[uint32][]tgrecord.Record)
+	for _, rec := range records {
+		byMethod[rec.Method] = append(byMethod[rec.Method], rec)
+	}
+	for method, recs := range byMethod {
+		f.handlers[method] = func(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+			var buf bin.Buffer
+			if err := req.Encode(&buf); err != nil {
+				return nil, err
+			}
+			for _, rec := range slices.Backward(recs) {
+				if bytes.Equal(rec.Request, buf.Raw()) {
+					return raw(rec.Response), nil
+				}
+			}
+			return nil, fmt.Errorf("tgfake: %T was not recorded with these parameters", req)
+		}
+	}
+}
+
+// raw is a response encoded already.
+type raw []byte
+
+func (r raw) Encode(b *bin.Buffer) error {
+	b.Put(r)
+	return nil
+}
+
+// FloodWait returns FLOOD_WAIT error asking to wait the seconds.
+func FloodWait(seconds int) error {
+	return tgerr.New(420, fmt.Sprintf("FLOOD_WAIT_%d", seconds))
+}
+
+// Error returns RPC error of Telegram, e.g. Error(400, "CHANNEL_PRIVATE").
+func Error(code int, typ string) error {
+	return tgerr.New(code, typ)
+}
+
+func typeID(req bin.Encoder) uint32 {
+	r, ok := req.(typeIDer)
+	if !ok {
+		panic(fmt.Sprintf("tgfake: request %T has no type id", req))
+	}
+	return r.TypeID()
+}
+
+func dateOf(m tg.MessageClass) int {
+	switch m := m.(type) {
+	case *tg.Message:
+		return m.Date
+	case *tg.MessageService:
+		return m.Date
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package tgfake
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testChannel = &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+
+func posts(ids ...int) []tg.MessageClass {
+	result := make([]tg.MessageClass, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, &tg.Message{ID: id, PeerID: &tg.PeerChannel{ChannelID: testChannel.ID}, Date: 1700000000 + id})
+	}
+	return result
+}
+
+func ids(res tg.MessagesMessagesClass) []int {
+	modified, _ := res.AsModified()
+	var result []int
+	for _, m := range modified.GetMessages() {
+		result = append(result, m.GetID())
+	}
+	return result
+}
+
+func TestInvoker_ResolveUsername(t *testing.T) {
+	ctx := context.Background()
+	f := New()
+	f.AddChannel(testChannel, nil)
+	api := tg.NewClient(f)
+
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: "WaterUtility"})
+	require.NoError(t, err)
+	require.Len(t, resolved.Chats, 1)
+	ch, ok := resolved.Chats[0].(*tg.Channel)
+	require.True(t, ok)
+	assert.Equal(t, int64(555), ch.AccessHash)
+	assert.True(t, ch.Broadcast)
+
+	_, err = api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: "unknown"})
+	assert.True(t, tgerr.Is(err, "USERNAME_NOT_OCCUPIED"))
+}
+
+func TestInvoker_GetHistory(t *testing.T) {
+	ctx := context.Background()
+	f := New()
+	f.AddChannel(testChannel, nil, posts(5, 1, 3, 2, 4)...)
+	api := tg.NewClient(f)
+	peer := testChannel.AsInputPeer()
+
+	res, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 4}, ids(res), "latest page should come newest first")
+
+	res, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 10, OffsetID: 4, MinID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, ids(res))
+
+	res, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 10, OffsetDate: 1700000003})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, ids(res))
+
+	f.Post(testChannel.ID, posts(6)...)
+	f.Delete(testChannel.ID, 5)
+	res, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{6, 4}, ids(res))
+
+	res, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 2, OffsetID: 2, AddOffset: -2, MinID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, ids(res), "negative add offset should page forwards from offset id")
+
+	_, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+		Peer:  &tg.InputPeerChannel{ChannelID: 200},
+		Limit: 2,
+	})
+	assert.True(t, tgerr.Is(err, "CHANNEL_INVALID"), "unknown channels should be rejected")
+}
+
+func TestInvoker_GetMessages(t *testing.T) {
+	f := New()
+	f.AddChannel(testChannel, nil, posts(1, 2)...)
+
+	res, err := tg.NewClient(f).ChannelsGetMessages(context.Background(), &tg.ChannelsGetMessagesRequest{
+		Channel: testChannel.AsInput(),
+		ID:      []tg.InputMessageClass{&tg.InputMessageID{ID: 2}, &tg.InputMessageID{ID: 3}},
+	})
+	require.NoError(t, err)
+	modified, _ := res.AsModified()
+	msgs := modified.GetMessages()
+	require.Len(t, msgs, 2)
+	assert.IsType(t, &tg.Message{}, msgs[0])
+	assert.IsType(t, &tg.MessageEmpty{}, msgs[1], "missing message should be empty")
+}
+
+func TestInvoker_FailAndHandle(t *testing.T) {
+	ctx := context.Background()
+	f := New()
+	f.AddChannel(testChannel, nil, posts(1)...)
+	api := tg.NewClient(f)
+	request := &tg.MessagesGetHistoryRequest{Peer: testChannel.AsInputPeer(), Limit: 10}
+
+	f.Fail(&tg.MessagesGetHistoryRequest{}, FloodWait(3), Error(500, "INTERNAL"))
+	_, err := api.MessagesGetHistory(ctx, request)
+	d, ok := tgerr.AsFloodWait(err)
+	require.True(t, ok)
+	assert.Equal(t, 3, int(d.Seconds()))
+	_, err = api.MessagesGetHistory(ctx, request)
+	assert.True(t, tgerr.IsCode(err, 500))
+	_, err = api.MessagesGetHistory(ctx, request)
+	require.NoError(t, err, "calls should be served after scripted failures")
+	assert.Equal(t, 3, f.Calls(&tg.MessagesGetHistoryRequest{}))
+
+	// basic groups are not served by built-in handlers
+	f.Handle(&tg.MessagesGetMessagesRequest{}, func(_ context.Context, req bin.Encoder) (bin.Encoder, error) {
+		id := req.(*tg.MessagesGetMessagesRequest).ID[0].(*tg.InputMessageID).ID
+		return &tg.MessagesMessages{
+			Messages: []tg.MessageClass{&tg.Message{ID: id, PeerID: &tg.PeerChat{ChatID: 300}, Message: "outage"}},
+			Chats:    []tg.ChatClass{},
+			Users:    []tg.UserClass{},
+		}, nil
+	})
+	res, err := api.MessagesGetMessages(ctx, []tg.InputMessageClass{&tg.InputMessageID{ID: 9}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{9}, ids(res))
+
+	_, err = api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{OffsetPeer: &tg.InputPeerEmpty{}})
+	assert.ErrorContains(t, err, "no handler")
+}
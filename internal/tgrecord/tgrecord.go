@@ -0,0 +1,163 @@
+// Package tgrecord records Telegram API calls into cassette files for replay in tests. A cassette is a
+// sequence of records, each holding the type id of the request, the request and its response TL-encoded
+// as on the wire, so responses replayed by tgfake.Invoker decode into the same objects.
+package tgrecord
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+// HistoryMethods are requests recorded to replay reading of channels: resolving, history pages,
+// comments and topics, and messages by ids.
+var HistoryMethods = []uint32{
+	tg.ContactsResolveUsernameRequestTypeID,
+	tg.MessagesGetHistoryRequestTypeID,
+	tg.MessagesGetRepliesRequestTypeID,
+	tg.ChannelsGetMessagesRequestTypeID,
+}
+
+// Record is a successful API call.
+type Record struct {
+	// Method is the type id of the request
+	Method   uint32
+	Request  []byte
+	Response []byte
+}
+
+type typeIDer interface {
+	TypeID() uint32
+}
+
+// Recorder is a middleware appending successful calls of the methods to a cassette.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	methods []uint32
+	// file is closed by Close, nil when the recorder writes to a writer it doesn't own
+	file *os.File
+}
+
+// NewRecorder records calls of the methods given as type ids of requests, e.g. HistoryMethods.
+func NewRecorder(w io.Writer, methods ...uint32) *Recorder {
+	return &Recorder{w: w, methods: methods}
+}
+
+// OpenRecorder appends calls to the cassette file, the file is created if it doesn't exist.
+// The file stays open until Close.
+func OpenRecorder(path string, methods ...uint32) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(f, methods...)
+	r.file = f
+	return r, nil
+}
+
+// Close closes the cassette file opened by OpenRecorder, writers given to NewRecorder are left open.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// Handle implements telegram.Middleware. Failing to record doesn't fail the call.
+func (r *Recorder) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		if err := next.Invoke(ctx, input, output); err != nil {
+			return err
+		}
+		req, ok := input.(typeIDer)
+		if !ok || !slices.Contains(r.methods, req.TypeID()) {
+			return nil
+		}
+		if err := r.record(req.TypeID(), input, output); err != nil {
+			log.Printf("record %T error: %v", input, err)
+		}
+		return nil
+	}
+}
+
+func (r *Recorder) record(method uint32, input bin.Encoder, output bin.Decoder) error {
+	res, ok := output.(bin.Encoder)
+	if !ok {
+		return fmt.Errorf("response %T can't be encoded", output)
+	}
+	var rec Record
+	var buf bin.Buffer
+	if err := input.Encode(&buf); err != nil {
+		return err
+	}
+	rec.Request = slices.Clone(buf.Raw())
+	buf.Reset()
+	if err := res.Encode(&buf); err != nil {
+		return err
+	}
+	rec.Response = slices.Clone(buf.Raw())
+	rec.Method = method
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Write(r.w, rec)
+}
+
+// Write appends the record to a cassette.
+func Write(w io.Writer, rec Record) error {
+	var buf bin.Buffer
+	buf.PutUint32(rec.Method)
+	buf.PutBytes(rec.Request)
+	buf.PutBytes(rec.Response)
+	_, err := w.Write(buf.Raw())
+	return err
+}
+
+// Read returns records of a cassette in recorded order.
+func Read(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bin.Buffer{Buf: data}
+	var records []Record
+	for buf.Len() > 0 {
+		var rec Record
+		if rec.Method, err = buf.Uint32(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records), err)
+		}
+		if rec.Request, err = buf.Bytes(); err != nil {
+			return nil, fmt.Errorf("record %d request: %w", len(records), err)
+		}
+		if rec.Response, err = buf.Bytes(); err != nil {
+			return nil, fmt.Errorf("record %d response: %w", len(records), err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Load reads records of the cassette file.
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	records, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return records, nil
+}
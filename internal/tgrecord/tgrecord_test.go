@@ -0,0 +1,94 @@
+// external package, as tgfake replays records of this one
+package tgrecord_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"tg-bridge/internal/tgfake"
+	"tg-bridge/internal/tgrecord"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_RecordsHistoryCalls(t *testing.T) {
+	ctx := context.Background()
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+	fake := tgfake.New()
+	fake.AddChannel(channel, nil, &tg.Message{ID: 1, Message: "outage", Date: 1700000000})
+
+	var cassette bytes.Buffer
+	api := tg.NewClient(tgrecord.NewRecorder(&cassette, tgrecord.HistoryMethods...).Handle(fake))
+
+	request := &tg.MessagesGetHistoryRequest{Peer: channel.AsInputPeer(), Limit: 10}
+	_, err := api.MessagesGetHistory(ctx, request)
+	require.NoError(t, err)
+
+	fake.Fail(&tg.MessagesGetHistoryRequest{}, tgfake.Error(500, "INTERNAL"))
+	_, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: channel.AsInputPeer(), Limit: 5})
+	require.Error(t, err)
+
+	_, err = api.ChannelsGetChannels(ctx, []tg.InputChannelClass{channel.AsInput()})
+	require.NoError(t, err)
+
+	records, err := tgrecord.Read(&cassette)
+	require.NoError(t, err)
+	require.Len(t, records, 1, "only successful calls of history methods should be recorded")
+	assert.Equal(t, uint32(tg.MessagesGetHistoryRequestTypeID), records[0].Method)
+
+	// replayed response is the recorded one
+	replay := tgfake.New()
+	replay.Replay(records...)
+	res, err := tg.NewClient(replay).MessagesGetHistory(ctx, request)
+	require.NoError(t, err)
+	modified, ok := res.AsModified()
+	require.True(t, ok)
+	require.Len(t, modified.GetMessages(), 1)
+	msg, ok := modified.GetMessages()[0].(*tg.Message)
+	require.True(t, ok)
+	assert.Equal(t, "outage", msg.Message)
+
+	_, err = tg.NewClient(replay).MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: channel.AsInputPeer(), Limit: 5})
+	assert.ErrorContains(t, err, "not recorded", "requests with other parameters should not be replayed")
+}
+
+func TestReadWrite(t *testing.T) {
+	var cassette bytes.Buffer
+	records := []tgrecord.Record{
+		{Method: 1, Request: []byte{1, 2, 3}, Response: []byte{4}},
+		{Method: 2, Request: []byte{9}, Response: bytes.Repeat([]byte{5}, 300)},
+	}
+	for _, rec := range records {
+		require.NoError(t, tgrecord.Write(&cassette, rec))
+	}
+
+	got, err := tgrecord.Read(bytes.NewReader(cassette.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, records, got)
+
+	_, err = tgrecord.Read(bytes.NewReader(cassette.Bytes()[:cassette.Len()-10]))
+	assert.Error(t, err, "truncated cassette should be rejected")
+}
+
+func TestOpenRecorder_AppendsToFile(t *testing.T) {
+	ctx := context.Background()
+	channel := &tg.Channel{ID: 100, AccessHash: 555, Username: "waterutility", Title: "Water", Broadcast: true}
+	fake := tgfake.New()
+	fake.AddChannel(channel, nil, &tg.Message{ID: 1, Message: "outage", Date: 1700000000})
+	path := filepath.Join(t.TempDir(), "history.cassette")
+
+	for range 2 {
+		recorder, err := tgrecord.OpenRecorder(path, tgrecord.HistoryMethods...)
+		require.NoError(t, err)
+		_, err = tg.NewClient(recorder.Handle(fake)).MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: channel.AsInputPeer(), Limit: 10})
+		require.NoError(t, err)
+		require.NoError(t, recorder.Close())
+	}
+
+	records, err := tgrecord.Load(path)
+	require.NoError(t, err)
+	assert.Len(t, records, 2, "reopened cassette should be appended to")
+}
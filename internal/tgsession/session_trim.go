@@ -1,7 +1,9 @@
 package tgsession
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/gotd/td/session"
@@ -15,6 +17,8 @@ type ShortTelegramSession struct {
 		AuthKey   []byte `json:"AuthKey"`
 		AuthKeyID []byte `json:"AuthKeyID"`
 		Salt      int64  `json:"Salt"`
+		// TestMode marks sessions of test DCs, production sessions omit it
+		TestMode bool `json:"TestMode,omitempty"`
 	} `json:"Data"`
 }
 
@@ -41,8 +45,29 @@ func TrimSession(fullSessionJSON []byte) ([]byte, error) {
 	shortSession.Data.AuthKey = fullSession.Data.AuthKey
 	shortSession.Data.AuthKeyID = fullSession.Data.AuthKeyID
 	shortSession.Data.Salt = fullSession.Data.Salt
+	shortSession.Data.TestMode = fullSession.Data.Config.TestMode
 
 	shortSessionJSON, err := json.MarshalIndent(shortSession, "", "  ")
 
 	return shortSessionJSON, err
 }
+
+// CheckDCMode returns an error if the base64 encoded session was generated for other DCs than
+// test DCs when testDC is set or production DCs otherwise, as its auth key is unknown there.
+func CheckDCMode(telegramSession string, testDC bool) error {
+	data, err := base64.StdEncoding.DecodeString(telegramSession)
+	if err != nil {
+		return fmt.Errorf("invalid base64 session data: %w", err)
+	}
+	var s ShortTelegramSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	switch {
+	case s.Data.TestMode && !testDC:
+		return errors.New("session was generated for test DCs, but production DCs are configured")
+	case !s.Data.TestMode && testDC:
+		return errors.New("session was generated for production DCs, but test DCs are configured")
+	}
+	return nil
+}
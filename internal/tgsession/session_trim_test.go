@@ -1,6 +1,8 @@
 package tgsession
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -83,3 +85,43 @@ func TestExtractShortSession_InvalidJSON(t *testing.T) {
 	assert.Error(t, err, "Should return error for invalid JSON")
 	assert.Nil(t, result, "Result should be nil for invalid JSON")
 }
+
+func TestExtractShortSession_TestMode(t *testing.T) {
+	fullSessionJSON := `{
+  "Version": 1,
+  "Data": {
+    "Config": {"TestMode": true, "ThisDC": 2},
+    "DC": 2,
+    "Addr": "149.154.167.40:443",
+    "AuthKey": "dGVzdC1hdXRoLWtleQ==",
+    "AuthKeyID": "12345678901234567890",
+    "Salt": 123456789
+  }
+}`
+	result, err := TrimSession([]byte(fullSessionJSON))
+	require.NoError(t, err)
+
+	var short ShortTelegramSession
+	require.NoError(t, json.Unmarshal(result, &short))
+	assert.True(t, short.Data.TestMode, "Session of test DCs should be marked")
+	assert.Equal(t, 2, short.Data.DC)
+}
+
+func TestCheckDCMode(t *testing.T) {
+	encode := func(testMode bool) string {
+		var s ShortTelegramSession
+		s.Version = 1
+		s.Data.DC = 2
+		s.Data.TestMode = testMode
+		data, err := json.Marshal(s)
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	prod, test := encode(false), encode(true)
+
+	assert.NoError(t, CheckDCMode(prod, false))
+	assert.NoError(t, CheckDCMode(test, true))
+	assert.ErrorContains(t, CheckDCMode(prod, true), "production DCs")
+	assert.ErrorContains(t, CheckDCMode(test, false), "test DCs")
+	assert.Error(t, CheckDCMode("not base64!", false))
+}